@@ -1,10 +1,45 @@
 package cgroups
 
 import (
+	"fmt"
+
 	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// DefaultCgroupParent is the cgroup under which every container gets its own cgroup
+	DefaultCgroupParent = "xperiMoby"
+	// CgroupfsDriver manages cgroups by writing to cgroupfs directly
+	CgroupfsDriver = "cgroupfs"
+	// SystemdDriver manages cgroups through transient systemd units
+	SystemdDriver = "systemd"
+)
+
+// Manager describes methods for cgroup drivers
+type Manager interface {
+	Apply(pid int) error
+	Set(res *subsystems.ResourceConfig) error
+	Destroy() error
+	GetStats() *subsystems.Stats
+	GetPids() ([]int, error)
+	NotifyOOM(done <-chan struct{}) (<-chan struct{}, error)
+	OOMKillCount() (uint64, error)
+	Freeze(state subsystems.FreezerState) error
+}
+
+// NewManager returns the manager of the cgroup at path for driver, cgroupfs by default
+func NewManager(driver, path string) (Manager, error) {
+	switch driver {
+	case "", CgroupfsDriver:
+		return NewCgroupManager(path), nil
+	case SystemdDriver:
+		return NewSystemdManager(path), nil
+	}
+	return nil, fmt.Errorf("unknown cgroup driver %s", driver)
+}
+
+// CgroupManager manages a cgroup through cgroupfs
 type CgroupManager struct {
 	Path     string
 	Resource *subsystems.ResourceConfig
@@ -16,18 +51,32 @@ func NewCgroupManager(path string) *CgroupManager {
 	}
 }
 
+// Apply moves pid into the cgroup of every subsystem and returns the first failure
 func (c *CgroupManager) Apply(pid int) error {
+	var applyErr error
 	for _, subSysIns := range subsystems.SubsystemsIns {
-		subSysIns.Apply(c.Path, pid)
+		if err := subSysIns.Apply(c.Path, pid); err != nil {
+			logrus.Warnf("apply %s cgroup fail %v", subSysIns.Name(), err)
+			if applyErr == nil {
+				applyErr = err
+			}
+		}
 	}
-	return nil
+	return applyErr
 }
 
+// Set applies res to every subsystem and returns the first failure
 func (c *CgroupManager) Set(res *subsystems.ResourceConfig) error {
+	var setErr error
 	for _, subSysIns := range subsystems.SubsystemsIns {
-		subSysIns.Set(c.Path, res)
+		if err := subSysIns.Set(c.Path, res); err != nil {
+			logrus.Warnf("set %s cgroup fail %v", subSysIns.Name(), err)
+			if setErr == nil {
+				setErr = err
+			}
+		}
 	}
-	return nil
+	return setErr
 }
 
 func (c *CgroupManager) Destroy() error {
@@ -38,3 +87,40 @@ func (c *CgroupManager) Destroy() error {
 	}
 	return nil
 }
+
+// GetPids returns the pids of all processes in the cgroup
+func (c *CgroupManager) GetPids() ([]int, error) {
+	return (&subsystems.PidsSubSystem{}).GetPids(c.Path)
+}
+
+// GetStats collects the resource usage of the cgroup from every subsystem providing it,
+// subsystems failing to report are left zero
+func (c *CgroupManager) GetStats() *subsystems.Stats {
+	stats := &subsystems.Stats{}
+	for _, subSysIns := range subsystems.SubsystemsIns {
+		getter, ok := subSysIns.(subsystems.StatsGetter)
+		if !ok {
+			continue
+		}
+		if err := getter.GetStats(c.Path, stats); err != nil {
+			logrus.Warnf("get %s stats fail %v", subSysIns.Name(), err)
+		}
+	}
+	return stats
+}
+
+// NotifyOOM returns a channel receiving a value every time the OOM killer fires in the cgroup
+// until done is closed
+func (c *CgroupManager) NotifyOOM(done <-chan struct{}) (<-chan struct{}, error) {
+	return (&subsystems.MemorySubSystem{}).NotifyOOM(c.Path, done)
+}
+
+// OOMKillCount returns how many processes of the cgroup the OOM killer has killed
+func (c *CgroupManager) OOMKillCount() (uint64, error) {
+	return (&subsystems.MemorySubSystem{}).OOMKillCount(c.Path)
+}
+
+// Freeze suspends or resumes all processes in the cgroup
+func (c *CgroupManager) Freeze(state subsystems.FreezerState) error {
+	return (&subsystems.FreezerSubSystem{}).Freeze(c.Path, state)
+}
@@ -0,0 +1,178 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// BlkioSubSystem is an implement of interface SubSystem
+type BlkioSubSystem struct {
+}
+
+// throttleDevice is a per-device bandwidth or iops limit
+type throttleDevice struct {
+	Major uint64
+	Minor uint64
+	Rate  uint64
+}
+
+func (d *throttleDevice) String() string {
+	return fmt.Sprintf("%d:%d %d", d.Major, d.Minor, d.Rate)
+}
+
+// Set set cgroup limit according to cgroupPath
+func (s *BlkioSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if IsCgroup2UnifiedMode() {
+		return setIO(subsysCgroupPath, res)
+	}
+	if res.BlkioWeight != "" {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "blkio.weight"), []byte(res.BlkioWeight), 0644); err != nil {
+			return fmt.Errorf("set cgroup blkio weight fail %v", err)
+		}
+	}
+	throttles := []struct {
+		file  string
+		specs []string
+		bytes bool
+	}{
+		{"blkio.throttle.read_bps_device", res.DeviceReadBps, true},
+		{"blkio.throttle.write_bps_device", res.DeviceWriteBps, true},
+		{"blkio.throttle.read_iops_device", res.DeviceReadIOps, false},
+		{"blkio.throttle.write_iops_device", res.DeviceWriteIOps, false},
+	}
+	for _, t := range throttles {
+		for _, spec := range t.specs {
+			device, err := parseThrottleDevice(spec, t.bytes)
+			if err != nil {
+				return err
+			}
+			// each write to a throttle file sets the limit of one device
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, t.file), []byte(device.String()), 0644); err != nil {
+				return fmt.Errorf("set cgroup %s fail %v", t.file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setIO writes blkio limits to io.weight and io.max of cgroup v2
+func setIO(subsysCgroupPath string, res *ResourceConfig) error {
+	if res.BlkioWeight != "" {
+		weight, err := strconv.ParseUint(res.BlkioWeight, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid blkio weight %s: %v", res.BlkioWeight, err)
+		}
+		// convert blkio.weight [10, 1000] to io.weight [1, 10000]
+		ioWeight := 1 + (weight-10)*9999/990
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "io.weight"), []byte(fmt.Sprintf("default %d", ioWeight)), 0644); err != nil {
+			return fmt.Errorf("set cgroup io weight fail %v", err)
+		}
+	}
+	// io.max takes all limits of a device in one line: "8:0 rbps=1048576 wiops=100"
+	var devices []string
+	limits := map[string][]string{}
+	throttles := []struct {
+		key   string
+		specs []string
+		bytes bool
+	}{
+		{"rbps", res.DeviceReadBps, true},
+		{"wbps", res.DeviceWriteBps, true},
+		{"riops", res.DeviceReadIOps, false},
+		{"wiops", res.DeviceWriteIOps, false},
+	}
+	for _, t := range throttles {
+		for _, spec := range t.specs {
+			device, err := parseThrottleDevice(spec, t.bytes)
+			if err != nil {
+				return err
+			}
+			dev := fmt.Sprintf("%d:%d", device.Major, device.Minor)
+			if _, ok := limits[dev]; !ok {
+				devices = append(devices, dev)
+			}
+			limits[dev] = append(limits[dev], fmt.Sprintf("%s=%d", t.key, device.Rate))
+		}
+	}
+	for _, dev := range devices {
+		line := dev + " " + strings.Join(limits[dev], " ")
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "io.max"), []byte(line), 0644); err != nil {
+			return fmt.Errorf("set cgroup io.max fail %v", err)
+		}
+	}
+	return nil
+}
+
+// Remove delete cgroup according to cgroupPath
+func (s *BlkioSubSystem) Remove(cgroupPath string) error {
+	return removeCgroup(s.Name(), cgroupPath)
+}
+
+// Apply add process to cgroup according to cgroupPath
+func (s *BlkioSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+}
+
+// Name returns subsystem name, the controller is called io on cgroup v2
+func (s *BlkioSubSystem) Name() string {
+	if IsCgroup2UnifiedMode() {
+		return "io"
+	}
+	return "blkio"
+}
+
+// parseThrottleDevice parses a `/dev/sda:10mb` style limit, rate is
+// a size when bytes is set and a plain number of operations otherwise
+func parseThrottleDevice(spec string, bytes bool) (*throttleDevice, error) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid device limit %q, expect <device-path>:<rate>", spec)
+	}
+	devicePath, rateStr := spec[:i], spec[i+1:]
+	var rate uint64
+	if bytes {
+		size, err := ParseSize(rateStr)
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("invalid device limit %q, rate must be positive", spec)
+		}
+		rate = uint64(size)
+	} else {
+		iops, err := strconv.ParseUint(rateStr, 10, 64)
+		if err != nil || iops == 0 {
+			return nil, fmt.Errorf("invalid device limit %q, iops must be a positive integer", spec)
+		}
+		rate = iops
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(devicePath, &stat); err != nil {
+		return nil, fmt.Errorf("stat device %s error %v", devicePath, err)
+	}
+	if stat.Mode&syscall.S_IFMT != syscall.S_IFBLK {
+		return nil, fmt.Errorf("%s is not a block device", devicePath)
+	}
+	return &throttleDevice{
+		Major: uint64(unix.Major(uint64(stat.Rdev))),
+		Minor: uint64(unix.Minor(uint64(stat.Rdev))),
+		Rate:  rate,
+	}, nil
+}
@@ -3,13 +3,45 @@ package subsystems
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"syscall"
 )
 
+const (
+	// UnifiedMountpoint is where the cgroup v2 unified hierarchy is mounted
+	UnifiedMountpoint = "/sys/fs/cgroup"
+	// cgroup2SuperMagic is the filesystem magic number of cgroup2, see statfs(2)
+	cgroup2SuperMagic = 0x63677270
+)
+
+var (
+	cgroup2Once sync.Once
+	cgroup2Mode bool
+)
+
+// IsCgroup2UnifiedMode reports whether the host only mounts the cgroup v2 unified hierarchy,
+// the result is detected once and cached for the lifetime of the process
+func IsCgroup2UnifiedMode() bool {
+	cgroup2Once.Do(func() {
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(UnifiedMountpoint, &st); err != nil {
+			return
+		}
+		cgroup2Mode = st.Type == cgroup2SuperMagic
+	})
+	return cgroup2Mode
+}
+
 // FindCgroupMountpoint get mountpoint path to a cgroup
 func FindCgroupMountpoint(subsystem string) string {
+	// all controllers share one hierarchy on cgroup v2
+	if IsCgroup2UnifiedMode() {
+		return UnifiedMountpoint
+	}
 	f, err := os.Open("/proc/self/mountinfo")
 	if err != nil {
 		return ""
@@ -38,11 +70,67 @@ func GetCgroupPath(subsystem string, cgroupPath string, autoCreate bool) (string
 	_, err := os.Stat(path.Join(cgroupRoot, cgroupPath))
 	if err == nil || (autoCreate && os.IsNotExist(err)) {
 		if os.IsNotExist(err) {
-			if err := os.Mkdir(path.Join(cgroupRoot, cgroupPath), 0755); err != nil {
+			if err := os.MkdirAll(path.Join(cgroupRoot, cgroupPath), 0755); err != nil {
 				return "", fmt.Errorf("error create cgroup %v", err)
 			}
 		}
+		if autoCreate && IsCgroup2UnifiedMode() {
+			if err := enableController(cgroupRoot, cgroupPath, subsystem); err != nil {
+				return "", err
+			}
+		}
 		return path.Join(cgroupRoot, cgroupPath), nil
 	}
 	return "", fmt.Errorf("cgroup path error %v", err)
 }
+
+// enableController delegates a cgroup v2 controller from the root down to cgroupPath
+// by writing it into cgroup.subtree_control of every ancestor
+func enableController(cgroupRoot, cgroupPath, controller string) error {
+	current := cgroupRoot
+	for _, elem := range strings.Split(path.Clean(cgroupPath), "/") {
+		if elem == "" {
+			continue
+		}
+		// controllers such as freezer and devices are built into cgroup v2 core
+		available, err := ioutil.ReadFile(path.Join(current, "cgroup.controllers"))
+		if err != nil {
+			return fmt.Errorf("read controllers of %s error %v", current, err)
+		}
+		if !containsField(string(available), controller) {
+			return nil
+		}
+		if err := ioutil.WriteFile(path.Join(current, "cgroup.subtree_control"), []byte("+"+controller), 0644); err != nil {
+			return fmt.Errorf("enable controller %s in %s error %v", controller, current, err)
+		}
+		current = path.Join(current, elem)
+	}
+	return nil
+}
+
+// procsFile returns the file a pid is written into to join a cgroup
+func procsFile() string {
+	if IsCgroup2UnifiedMode() {
+		return "cgroup.procs"
+	}
+	return "tasks"
+}
+
+// removeCgroup deletes a cgroup, which may already be gone when
+// several subsystems share the unified hierarchy
+func removeCgroup(subsystem, cgroupPath string) error {
+	subsysCgroupPath := path.Join(FindCgroupMountpoint(subsystem), cgroupPath)
+	if err := os.Remove(subsysCgroupPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func containsField(s, field string) bool {
+	for _, f := range strings.Fields(s) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,14 @@ package subsystems
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
+	"strings"
 )
 
+// defaultCFSPeriod is the kernel default cpu.cfs_period_us
+const defaultCFSPeriod = 100000
+
 // CPUSubSystem is an implement of interface SubSystem
 type CPUSubSystem struct {
 }
@@ -17,29 +20,81 @@ func (s *CPUSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
 	if err == nil {
 		if res.CPUShare != "" {
-			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "CPU.shares"), []byte(res.CPUShare), 0644); err != nil {
+			shareFile, share := "cpu.shares", res.CPUShare
+			if IsCgroup2UnifiedMode() {
+				weight, err := SharesToWeight(res.CPUShare)
+				if err != nil {
+					return err
+				}
+				shareFile, share = "cpu.weight", strconv.FormatUint(weight, 10)
+			}
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, shareFile), []byte(share), 0644); err != nil {
 				return fmt.Errorf("set cgroup CPU share fail %v", err)
 			}
 		}
-		return nil
+		return setCFSBandwidth(subsysCgroupPath, res)
 	}
 	return err
 }
 
+// setCFSBandwidth writes the hard CPU cap to cpu.cfs_quota_us and cpu.cfs_period_us,
+// or to cpu.max on cgroup v2
+func setCFSBandwidth(subsysCgroupPath string, res *ResourceConfig) error {
+	quota, period, err := res.cfsQuotaAndPeriod()
+	if err != nil {
+		return err
+	}
+	if IsCgroup2UnifiedMode() {
+		if quota == 0 && period == 0 {
+			return nil
+		}
+		// cpu.max accepts "$MAX $PERIOD", keep the current value of any unset part
+		current, err := ioutil.ReadFile(path.Join(subsysCgroupPath, "cpu.max"))
+		if err != nil {
+			return fmt.Errorf("read cgroup cpu.max fail %v", err)
+		}
+		fields := strings.Fields(string(current))
+		if len(fields) != 2 {
+			return fmt.Errorf("unexpected cpu.max content %q", current)
+		}
+		quotaStr, periodStr := fields[0], fields[1]
+		if quota == -1 {
+			quotaStr = "max"
+		} else if quota > 0 {
+			quotaStr = strconv.FormatInt(quota, 10)
+		}
+		if period > 0 {
+			periodStr = strconv.FormatUint(period, 10)
+		}
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "cpu.max"), []byte(quotaStr+" "+periodStr), 0644); err != nil {
+			return fmt.Errorf("set cgroup cpu.max fail %v", err)
+		}
+		return nil
+	}
+	// the period is set first since the kernel validates quota against it
+	if period > 0 {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_period_us"), []byte(strconv.FormatUint(period, 10)), 0644); err != nil {
+			return fmt.Errorf("set cgroup CPU period fail %v", err)
+		}
+	}
+	if quota != 0 {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "cpu.cfs_quota_us"), []byte(strconv.FormatInt(quota, 10)), 0644); err != nil {
+			return fmt.Errorf("set cgroup CPU quota fail %v", err)
+		}
+	}
+	return nil
+}
+
 // Remove delete cgroup according to cgroupPath
 func (s *CPUSubSystem) Remove(cgroupPath string) error {
-	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
-	if err == nil {
-		return os.RemoveAll(subsysCgroupPath)
-	}
-	return err
+	return removeCgroup(s.Name(), cgroupPath)
 }
 
 // Apply add process to cgroup according to cgroupPath
 func (s *CPUSubSystem) Apply(cgroupPath string, pid int) error {
 	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
 	if err == nil {
-		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -49,5 +104,20 @@ func (s *CPUSubSystem) Apply(cgroupPath string, pid int) error {
 
 // Name returns subsystem name
 func (s *CPUSubSystem) Name() string {
-	return "CPU"
+	return "cpu"
+}
+
+// SharesToWeight converts cgroup v1 cpu.shares [2, 262144] to cgroup v2 cpu.weight [1, 10000]
+func SharesToWeight(shares string) (uint64, error) {
+	s, err := strconv.ParseUint(shares, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CPU share %s: %v", shares, err)
+	}
+	if s < 2 {
+		s = 2
+	}
+	if s > 262144 {
+		s = 262144
+	}
+	return 1 + ((s-2)*9999)/262142, nil
 }
@@ -3,9 +3,9 @@ package subsystems
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
+	"strings"
 )
 
 // CPUSetSubSystem is an implement of interface SubSystem
@@ -16,8 +16,13 @@ type CPUSetSubSystem struct {
 func (s *CPUSetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
 	if err == nil {
+		if !IsCgroup2UnifiedMode() {
+			if err := inheritCPUSet(FindCgroupMountpoint(s.Name()), cgroupPath); err != nil {
+				return err
+			}
+		}
 		if res.CPUSet != "" {
-			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "CPUset.CPUs"), []byte(res.CPUSet), 0644); err != nil {
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "cpuset.cpus"), []byte(res.CPUSet), 0644); err != nil {
 				return fmt.Errorf("set cgroup CPUset fail %v", err)
 			}
 		}
@@ -28,18 +33,14 @@ func (s *CPUSetSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 
 // Remove delete cgroup according to cgroupPath
 func (s *CPUSetSubSystem) Remove(cgroupPath string) error {
-	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
-	if err == nil {
-		return os.RemoveAll(subsysCgroupPath)
-	}
-	return err
+	return removeCgroup(s.Name(), cgroupPath)
 }
 
 // Apply add process to cgroup according to cgroupPath
 func (s *CPUSetSubSystem) Apply(cgroupPath string, pid int) error {
 	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
 	if err == nil {
-		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup proc fail %v", err)
 		}
 		return nil
@@ -49,5 +50,34 @@ func (s *CPUSetSubSystem) Apply(cgroupPath string, pid int) error {
 
 // Name returns subsystem name
 func (s *CPUSetSubSystem) Name() string {
-	return "CPUset"
+	return "cpuset"
+}
+
+// inheritCPUSet fills empty cpuset.cpus and cpuset.mems of every cgroup v1 level
+// with the values of its parent, tasks can't join a cpuset cgroup without them
+func inheritCPUSet(cgroupRoot, cgroupPath string) error {
+	parent := cgroupRoot
+	for _, elem := range strings.Split(path.Clean(cgroupPath), "/") {
+		if elem == "" {
+			continue
+		}
+		current := path.Join(parent, elem)
+		for _, file := range []string{"cpuset.cpus", "cpuset.mems"} {
+			value, err := ioutil.ReadFile(path.Join(current, file))
+			if err != nil {
+				return fmt.Errorf("read %s error %v", file, err)
+			}
+			if strings.TrimSpace(string(value)) != "" {
+				continue
+			}
+			if value, err = ioutil.ReadFile(path.Join(parent, file)); err != nil {
+				return fmt.Errorf("read %s error %v", file, err)
+			}
+			if err := ioutil.WriteFile(path.Join(current, file), value, 0644); err != nil {
+				return fmt.Errorf("set %s fail %v", file, err)
+			}
+		}
+		parent = current
+	}
+	return nil
 }
@@ -0,0 +1,90 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DeviceRule allows access to a device, -1 major or minor matches any number
+type DeviceRule struct {
+	Type   string `json:"type"` // "c" for char, "b" for block, "a" for all devices
+	Major  int64  `json:"major"`
+	Minor  int64  `json:"minor"`
+	Access string `json:"access"` // any combination of r(ead), w(rite) and m(knod)
+}
+
+func (r DeviceRule) String() string {
+	major, minor := "*", "*"
+	if r.Major >= 0 {
+		major = strconv.FormatInt(r.Major, 10)
+	}
+	if r.Minor >= 0 {
+		minor = strconv.FormatInt(r.Minor, 10)
+	}
+	return fmt.Sprintf("%s %s:%s %s", r.Type, major, minor, r.Access)
+}
+
+// DevicesSubSystem is an implement of interface SubSystem
+type DevicesSubSystem struct {
+}
+
+// Set denies all devices but the allowed ones through devices.deny and devices.allow,
+// or a BPF device program on cgroup v2
+func (s *DevicesSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	// no device policy configured
+	if res.Devices == nil {
+		return nil
+	}
+	if IsCgroup2UnifiedMode() {
+		return attachDeviceFilter(subsysCgroupPath, res.Devices)
+	}
+	if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "devices.deny"), []byte("a"), 0644); err != nil {
+		return fmt.Errorf("set cgroup devices.deny fail %v", err)
+	}
+	for _, rule := range res.Devices {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "devices.allow"), []byte(rule.String()), 0644); err != nil {
+			return fmt.Errorf("allow device %s fail %v", rule, err)
+		}
+	}
+	return nil
+}
+
+// Remove delete cgroup according to cgroupPath
+func (s *DevicesSubSystem) Remove(cgroupPath string) error {
+	return removeCgroup(s.Name(), cgroupPath)
+}
+
+// Apply add process to cgroup according to cgroupPath
+func (s *DevicesSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+}
+
+// Name returns subsystem name
+func (s *DevicesSubSystem) Name() string {
+	return "devices"
+}
+
+// Validate checks the type and access of the rule
+func (r DeviceRule) Validate() error {
+	if r.Type != "a" && r.Type != "b" && r.Type != "c" {
+		return fmt.Errorf("invalid device type %q", r.Type)
+	}
+	if r.Access == "" || strings.Trim(r.Access, "rwm") != "" {
+		return fmt.Errorf("invalid device access %q, expect a combination of r, w and m", r.Access)
+	}
+	return nil
+}
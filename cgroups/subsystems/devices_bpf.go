@@ -0,0 +1,167 @@
+package subsystems
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// constants from linux/bpf.h and linux/bpf_common.h
+const (
+	bpfProgLoad   = 5
+	bpfProgAttach = 8
+
+	bpfProgTypeCgroupDevice = 15
+	bpfCgroupDevice         = 6
+
+	bpfDevcgDevBlock = 1
+	bpfDevcgDevChar  = 2
+
+	bpfDevcgAccMknod = 1
+	bpfDevcgAccRead  = 2
+	bpfDevcgAccWrite = 4
+
+	// opcodes of the instructions used by the device program
+	opLdxMemW  = 0x61 // dst = *(u32 *)(src + off)
+	opAndImm32 = 0x54 // dst &= imm
+	opRshImm32 = 0x74 // dst >>= imm
+	opMovReg32 = 0xbc // dst = src
+	opMovImm   = 0xb7 // dst = imm
+	opJneImm   = 0x55 // if dst != imm goto pc + off
+	opExit     = 0x95 // return r0
+)
+
+// bpfInsn is struct bpf_insn
+type bpfInsn struct {
+	code uint8
+	regs uint8 // dst in the low nibble, src in the high nibble
+	off  int16
+	imm  int32
+}
+
+func insn(code, dst, src uint8, off int16, imm int32) bpfInsn {
+	return bpfInsn{code: code, regs: dst | src<<4, off: off, imm: imm}
+}
+
+// deviceFilter compiles rules into a BPF_PROG_TYPE_CGROUP_DEVICE program, which receives
+// struct bpf_cgroup_dev_ctx { u32 access_type; u32 major; u32 minor; } in r1
+// and returns 1 to allow the access or 0 to deny it
+func deviceFilter(rules []DeviceRule) []bpfInsn {
+	prog := []bpfInsn{
+		insn(opLdxMemW, 2, 1, 0, 0),       // r2 = ctx->access_type
+		insn(opAndImm32, 2, 0, 0, 0xffff), // r2 = device type
+		insn(opLdxMemW, 3, 1, 0, 0),       // r3 = ctx->access_type
+		insn(opRshImm32, 3, 0, 0, 16),     // r3 = access
+		insn(opLdxMemW, 4, 1, 4, 0),       // r4 = ctx->major
+		insn(opLdxMemW, 5, 1, 8, 0),       // r5 = ctx->minor
+	}
+	for _, rule := range rules {
+		// every check jumps to the next rule on mismatch, the offset is patched below
+		var block []bpfInsn
+		switch rule.Type {
+		case "b":
+			block = append(block, insn(opJneImm, 2, 0, 0, bpfDevcgDevBlock))
+		case "c":
+			block = append(block, insn(opJneImm, 2, 0, 0, bpfDevcgDevChar))
+		}
+		if access := deviceAccess(rule.Access); access != bpfDevcgAccMknod|bpfDevcgAccRead|bpfDevcgAccWrite {
+			block = append(block,
+				insn(opMovReg32, 1, 3, 0, 0),
+				insn(opAndImm32, 1, 0, 0, int32(^access&0xffff)),
+				insn(opJneImm, 1, 0, 0, 0),
+			)
+		}
+		if rule.Major >= 0 {
+			block = append(block, insn(opJneImm, 4, 0, 0, int32(rule.Major)))
+		}
+		if rule.Minor >= 0 {
+			block = append(block, insn(opJneImm, 5, 0, 0, int32(rule.Minor)))
+		}
+		block = append(block, insn(opMovImm, 0, 0, 0, 1), insn(opExit, 0, 0, 0, 0))
+		for i := range block {
+			if block[i].code == opJneImm {
+				block[i].off = int16(len(block) - i - 1)
+			}
+		}
+		prog = append(prog, block...)
+	}
+	return append(prog, insn(opMovImm, 0, 0, 0, 0), insn(opExit, 0, 0, 0, 0))
+}
+
+func deviceAccess(access string) int32 {
+	var mask int32
+	if strings.Contains(access, "m") {
+		mask |= bpfDevcgAccMknod
+	}
+	if strings.Contains(access, "r") {
+		mask |= bpfDevcgAccRead
+	}
+	if strings.Contains(access, "w") {
+		mask |= bpfDevcgAccWrite
+	}
+	return mask
+}
+
+// attachDeviceFilter loads the device program of rules and attaches it to the cgroup,
+// replacing the program attached before
+func attachDeviceFilter(subsysCgroupPath string, rules []DeviceRule) error {
+	prog := deviceFilter(rules)
+	insns := make([]byte, 8*len(prog))
+	for i, in := range prog {
+		insns[8*i] = in.code
+		insns[8*i+1] = in.regs
+		binary.LittleEndian.PutUint16(insns[8*i+2:], uint16(in.off))
+		binary.LittleEndian.PutUint32(insns[8*i+4:], uint32(in.imm))
+	}
+	license := []byte("Apache\x00")
+	loadAttr := struct {
+		progType    uint32
+		insnCnt     uint32
+		insns       uint64
+		license     uint64
+		logLevel    uint32
+		logSize     uint32
+		logBuf      uint64
+		kernVersion uint32
+		progFlags   uint32
+	}{
+		progType: bpfProgTypeCgroupDevice,
+		insnCnt:  uint32(len(prog)),
+		insns:    uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+	progFd, _, errno := syscall.Syscall(unix.SYS_BPF, bpfProgLoad, uintptr(unsafe.Pointer(&loadAttr)), unsafe.Sizeof(loadAttr))
+	// the kernel only sees the addresses of insns and license
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(license)
+	if errno != 0 {
+		return fmt.Errorf("load device program error %v", errno)
+	}
+	defer syscall.Close(int(progFd))
+
+	cgroupDir, err := os.Open(subsysCgroupPath)
+	if err != nil {
+		return err
+	}
+	defer cgroupDir.Close()
+	attachAttr := struct {
+		targetFd    uint32
+		attachBpfFd uint32
+		attachType  uint32
+		attachFlags uint32
+	}{
+		targetFd:    uint32(cgroupDir.Fd()),
+		attachBpfFd: uint32(progFd),
+		attachType:  bpfCgroupDevice,
+	}
+	if _, _, errno := syscall.Syscall(unix.SYS_BPF, bpfProgAttach, uintptr(unsafe.Pointer(&attachAttr)), unsafe.Sizeof(attachAttr)); errno != 0 {
+		return fmt.Errorf("attach device program to %s error %v", subsysCgroupPath, errno)
+	}
+	return nil
+}
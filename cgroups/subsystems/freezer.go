@@ -0,0 +1,100 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FreezerState is the state of a freezer cgroup
+type FreezerState string
+
+const (
+	// Frozen suspends all processes of the cgroup
+	Frozen FreezerState = "FROZEN"
+	// Thawed resumes all processes of the cgroup
+	Thawed FreezerState = "THAWED"
+)
+
+// freezeTimeout is how long to wait for the kernel to finish freezing or thawing
+const freezeTimeout = 10 * time.Second
+
+// FreezerSubSystem is an implement of interface SubSystem
+type FreezerSubSystem struct {
+}
+
+// Set creates the freezer cgroup, there is no limit to set
+func (s *FreezerSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	_, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	return err
+}
+
+// Remove delete cgroup according to cgroupPath
+func (s *FreezerSubSystem) Remove(cgroupPath string) error {
+	return removeCgroup(s.Name(), cgroupPath)
+}
+
+// Apply add process to cgroup according to cgroupPath
+func (s *FreezerSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+}
+
+// Name returns subsystem name
+func (s *FreezerSubSystem) Name() string {
+	return "freezer"
+}
+
+// Freeze changes the state of the cgroup through freezer.state, or cgroup.freeze on cgroup v2,
+// and waits until all processes reach it
+func (s *FreezerSubSystem) Freeze(cgroupPath string, state FreezerState) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	stateFile, value, wanted := "freezer.state", string(state), string(state)
+	if IsCgroup2UnifiedMode() {
+		// cgroup.events reports "frozen 1" once every process is frozen
+		stateFile, value, wanted = "cgroup.freeze", "0", "0"
+		if state == Frozen {
+			value, wanted = "1", "1"
+		}
+	}
+	if err := ioutil.WriteFile(path.Join(subsysCgroupPath, stateFile), []byte(value), 0644); err != nil {
+		return fmt.Errorf("set cgroup freezer state fail %v", err)
+	}
+	deadline := time.Now().Add(freezeTimeout)
+	for time.Now().Before(deadline) {
+		current, err := s.currentState(subsysCgroupPath)
+		if err != nil {
+			return err
+		}
+		if current == wanted {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("timeout waiting for cgroup %s to be %s", cgroupPath, state)
+}
+
+func (s *FreezerSubSystem) currentState(subsysCgroupPath string) (string, error) {
+	if IsCgroup2UnifiedMode() {
+		frozen, err := readKeyedUint(path.Join(subsysCgroupPath, "cgroup.events"), "frozen")
+		return strconv.FormatUint(frozen, 10), err
+	}
+	// freezer.state reads FREEZING until every task is frozen
+	content, err := ioutil.ReadFile(path.Join(subsysCgroupPath, "freezer.state"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,101 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// HugetlbSubSystem is an implement of interface SubSystem
+type HugetlbSubSystem struct {
+}
+
+// hugetlbLimit is the limit of one huge page size
+type hugetlbLimit struct {
+	PageSize string // kernel name of the page size, e.g. 2MB
+	Limit    int64
+}
+
+// parseHugetlbLimit parses a hugetlb limit in the form <pagesize>:<limit>, e.g. 2MB:1g
+func parseHugetlbLimit(spec string) (*hugetlbLimit, error) {
+	fields := strings.Split(spec, ":")
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid hugetlb limit %q, expected <pagesize>:<limit>", spec)
+	}
+	pageSize, err := ParseSize(fields[0])
+	if err != nil || pageSize <= 0 {
+		return nil, fmt.Errorf("invalid hugetlb page size %q", fields[0])
+	}
+	limit, err := ParseSize(fields[1])
+	if err != nil || limit <= 0 {
+		return nil, fmt.Errorf("invalid hugetlb limit %q", fields[1])
+	}
+	return &hugetlbLimit{PageSize: hugePageSizeName(pageSize), Limit: limit}, nil
+}
+
+// hugePageSizeName formats a page size the way the kernel names hugetlb files
+func hugePageSizeName(size int64) string {
+	switch {
+	case size >= 1<<30 && size%(1<<30) == 0:
+		return fmt.Sprintf("%dGB", size>>30)
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", size>>20)
+	default:
+		return fmt.Sprintf("%dKB", size>>10)
+	}
+}
+
+// Set set cgroup limit according to cgroupPath
+func (s *HugetlbSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	// the cgroup is created even without limits so that Apply finds it
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err != nil {
+		return err
+	}
+	if len(res.HugetlbLimits) == 0 {
+		return nil
+	}
+	suffix := "limit_in_bytes"
+	if IsCgroup2UnifiedMode() {
+		suffix = "max"
+	}
+	for _, spec := range res.HugetlbLimits {
+		limit, err := parseHugetlbLimit(spec)
+		if err != nil {
+			return err
+		}
+		file := path.Join(subsysCgroupPath, fmt.Sprintf("hugetlb.%s.%s", limit.PageSize, suffix))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return fmt.Errorf("huge page size %s is not supported", limit.PageSize)
+		}
+		if err := ioutil.WriteFile(file, []byte(strconv.FormatInt(limit.Limit, 10)), 0644); err != nil {
+			return fmt.Errorf("set cgroup hugetlb %s fail %v", limit.PageSize, err)
+		}
+	}
+	return nil
+}
+
+// Remove delete cgroup according to cgroupPath
+func (s *HugetlbSubSystem) Remove(cgroupPath string) error {
+	return removeCgroup(s.Name(), cgroupPath)
+}
+
+// Apply add process to cgroup according to cgroupPath
+func (s *HugetlbSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+}
+
+// Name returns subsystem name
+func (s *HugetlbSubSystem) Name() string {
+	return "hugetlb"
+}
@@ -3,9 +3,9 @@ package subsystems
 import (
 	"fmt"
 	"io/ioutil"
-	"os"
 	"path"
 	"strconv"
+	"strings"
 )
 
 // MemorySubSystem is an implement of interface SubSystem
@@ -15,25 +15,108 @@ type MemorySubSystem struct {
 // Set set cgroup limit according to cgroupPath
 func (s *MemorySubSystem) Set(cgroupPath string, res *ResourceConfig) error {
 	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
-	if err == nil {
-		if res.MemoryLimit != "" {
-			// set cgroup memory_limit and write it to memory.limit_in_bytes
-			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "memory.limit_in_bytes"), []byte(res.MemoryLimit), 0644); err != nil {
-				return fmt.Errorf("set cgroup memory fail %v", err)
-			}
+	if err != nil {
+		return err
+	}
+	limits, err := res.memoryLimits()
+	if err != nil {
+		return err
+	}
+	if IsCgroup2UnifiedMode() {
+		return setMemoryV2(subsysCgroupPath, limits)
+	}
+	if limits.limit != 0 || limits.swap != 0 {
+		if err := setMemoryAndSwap(subsysCgroupPath, limits); err != nil {
+			return err
 		}
-		return nil
 	}
-	return err
+	if limits.reservation != 0 {
+		if err := writeMemoryFile(subsysCgroupPath, "memory.soft_limit_in_bytes", limits.reservation); err != nil {
+			return err
+		}
+	}
+	if res.OOMKillDisable {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "memory.oom_control"), []byte("1"), 0644); err != nil {
+			return fmt.Errorf("disable cgroup oom killer fail %v", err)
+		}
+	}
+	return nil
+}
+
+// setMemoryAndSwap writes memory.limit_in_bytes and memory.memsw.limit_in_bytes in an order
+// the kernel accepts, the memory limit can never exceed the memory+swap limit
+func setMemoryAndSwap(subsysCgroupPath string, limits *memoryLimits) error {
+	swapFirst := false
+	if limits.swap != 0 {
+		current, err := ioutil.ReadFile(path.Join(subsysCgroupPath, "memory.memsw.limit_in_bytes"))
+		if err != nil {
+			return fmt.Errorf("swap limit is not supported, enable swapaccount on the kernel command line: %v", err)
+		}
+		currentSwap, err := strconv.ParseInt(strings.TrimSpace(string(current)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse memory.memsw.limit_in_bytes error %v", err)
+		}
+		swapFirst = limits.swap == -1 || limits.swap > currentSwap
+	}
+	if swapFirst {
+		if err := writeMemoryFile(subsysCgroupPath, "memory.memsw.limit_in_bytes", limits.swap); err != nil {
+			return err
+		}
+	}
+	if limits.limit != 0 {
+		// set cgroup memory_limit and write it to memory.limit_in_bytes
+		if err := writeMemoryFile(subsysCgroupPath, "memory.limit_in_bytes", limits.limit); err != nil {
+			return err
+		}
+	}
+	if limits.swap != 0 && !swapFirst {
+		if err := writeMemoryFile(subsysCgroupPath, "memory.memsw.limit_in_bytes", limits.swap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setMemoryV2 writes memory limits to memory.max, memory.swap.max and memory.low of cgroup v2
+func setMemoryV2(subsysCgroupPath string, limits *memoryLimits) error {
+	if limits.limit != 0 {
+		if err := writeMemoryFile(subsysCgroupPath, "memory.max", limits.limit); err != nil {
+			return err
+		}
+	}
+	// memory.swap.max only counts swap while --memory-swap is memory plus swap
+	switch {
+	case limits.swap == -1:
+		if err := writeMemoryFile(subsysCgroupPath, "memory.swap.max", -1); err != nil {
+			return err
+		}
+	case limits.swap > 0 && limits.limit > 0:
+		if err := writeMemoryFile(subsysCgroupPath, "memory.swap.max", limits.swap-limits.limit); err != nil {
+			return err
+		}
+	}
+	if limits.reservation != 0 {
+		if err := writeMemoryFile(subsysCgroupPath, "memory.low", limits.reservation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMemoryFile(subsysCgroupPath, file string, value int64) error {
+	content := strconv.FormatInt(value, 10)
+	if value == -1 && IsCgroup2UnifiedMode() {
+		content = "max"
+	}
+	if err := ioutil.WriteFile(path.Join(subsysCgroupPath, file), []byte(content), 0644); err != nil {
+		return fmt.Errorf("set cgroup %s fail %v", file, err)
+	}
+	return nil
 }
 
 // Remove delete cgroup according to cgroupPath
 func (s *MemorySubSystem) Remove(cgroupPath string) error {
-	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
-	if err == nil {
-		return os.Remove(subsysCgroupPath)
-	}
-	return err
+	return removeCgroup(s.Name(), cgroupPath)
 }
 
 // Apply add process to cgroup according to cgroupPath
@@ -41,7 +124,7 @@ func (s *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
 	if err == nil {
 		// wirte pid to task of cgroup in virtual file system
-		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "tasks"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
 			return fmt.Errorf("set cgroup fail %v ", err)
 		}
 		return nil
@@ -53,3 +136,80 @@ func (s *MemorySubSystem) Apply(cgroupPath string, pid int) error {
 func (s *MemorySubSystem) Name() string {
 	return "memory"
 }
+
+// memoryLimits holds the memory limits in bytes, 0 means unset and -1 unlimited
+type memoryLimits struct {
+	limit       int64
+	swap        int64
+	reservation int64
+}
+
+// memoryLimits parses the human-readable memory sizes of res
+func (r *ResourceConfig) memoryLimits() (*memoryLimits, error) {
+	limits := &memoryLimits{}
+	var err error
+	if limits.limit, err = parseMemory(r.MemoryLimit, "memory"); err != nil {
+		return nil, err
+	}
+	if limits.swap, err = parseMemory(r.MemorySwap, "memory-swap"); err != nil {
+		return nil, err
+	}
+	if limits.reservation, err = parseMemory(r.MemoryReservation, "memory-reservation"); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func parseMemory(size, name string) (int64, error) {
+	if size == "" {
+		return 0, nil
+	}
+	if size == "-1" {
+		return -1, nil
+	}
+	bytes, err := ParseSize(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	if bytes <= 0 {
+		return 0, fmt.Errorf("invalid %s %s, must be positive", name, size)
+	}
+	return bytes, nil
+}
+
+// validateMemory checks memory options which only make sense together
+func (r *ResourceConfig) validateMemory() error {
+	limits, err := r.memoryLimits()
+	if err != nil {
+		return err
+	}
+	if limits.limit == -1 {
+		return fmt.Errorf("invalid memory %s, must be positive", r.MemoryLimit)
+	}
+	if limits.swap != 0 {
+		if limits.limit <= 0 {
+			return fmt.Errorf("memory-swap can not be set without memory")
+		}
+		if limits.swap != -1 && limits.swap < limits.limit {
+			return fmt.Errorf("memory-swap %s should be larger than memory %s", r.MemorySwap, r.MemoryLimit)
+		}
+	}
+	if limits.reservation == -1 {
+		return fmt.Errorf("invalid memory-reservation %s, must be positive", r.MemoryReservation)
+	}
+	if limits.reservation > 0 && limits.limit > 0 && limits.reservation > limits.limit {
+		return fmt.Errorf("memory-reservation %s should be smaller than memory %s", r.MemoryReservation, r.MemoryLimit)
+	}
+	if r.OOMKillDisable {
+		if IsCgroup2UnifiedMode() {
+			return fmt.Errorf("oom-kill-disable is not supported on cgroup v2")
+		}
+		if limits.limit <= 0 {
+			return fmt.Errorf("oom-kill-disable without memory limit may hang the host")
+		}
+	}
+	if r.OOMScoreAdj < -1000 || r.OOMScoreAdj > 1000 {
+		return fmt.Errorf("oom-score-adj %d out of range [-1000, 1000]", r.OOMScoreAdj)
+	}
+	return nil
+}
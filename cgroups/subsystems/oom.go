@@ -0,0 +1,138 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"syscall"
+	"unsafe"
+)
+
+// NotifyOOM returns a channel receiving a value every time the OOM killer fires
+// inside the cgroup, the channel is closed once the cgroup is removed or done is closed
+// and has to be drained until then
+func (s *MemorySubSystem) NotifyOOM(cgroupPath string, done <-chan struct{}) (<-chan struct{}, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	if IsCgroup2UnifiedMode() {
+		return notifyOOMV2(subsysCgroupPath, done)
+	}
+	return notifyOOMV1(subsysCgroupPath, done)
+}
+
+// OOMKillCount returns how many processes of the cgroup the OOM killer has killed since
+// the cgroup was created
+func (s *MemorySubSystem) OOMKillCount(cgroupPath string) (uint64, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return 0, err
+	}
+	// both files have an "oom_kill <count>" line, cgroup v1 has it since linux 4.13
+	eventsFile := "memory.oom_control"
+	if IsCgroup2UnifiedMode() {
+		eventsFile = "memory.events"
+	}
+	return readKeyedUint(path.Join(subsysCgroupPath, eventsFile), "oom_kill")
+}
+
+// notifyOOMV1 registers an eventfd for memory.oom_control through cgroup.event_control
+func notifyOOMV1(subsysCgroupPath string, done <-chan struct{}) (<-chan struct{}, error) {
+	oomControl, err := os.Open(path.Join(subsysCgroupPath, "memory.oom_control"))
+	if err != nil {
+		return nil, err
+	}
+	// a non-blocking fd goes through the poller, so closing it interrupts a pending read
+	efd, _, errno := syscall.RawSyscall(syscall.SYS_EVENTFD2, 0, syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if errno != 0 {
+		oomControl.Close()
+		return nil, fmt.Errorf("create eventfd error %v", errno)
+	}
+	eventfd := os.NewFile(efd, "eventfd")
+	eventControl := path.Join(subsysCgroupPath, "cgroup.event_control")
+	data := fmt.Sprintf("%d %d", eventfd.Fd(), oomControl.Fd())
+	if err := ioutil.WriteFile(eventControl, []byte(data), 0700); err != nil {
+		eventfd.Close()
+		oomControl.Close()
+		return nil, fmt.Errorf("register oom event error %v", err)
+	}
+	ch := make(chan struct{})
+	go closeOnDone(done, eventfd)
+	go func() {
+		defer func() {
+			close(ch)
+			eventfd.Close()
+			oomControl.Close()
+		}()
+		buf := make([]byte, 8)
+		for {
+			if _, err := eventfd.Read(buf); err != nil {
+				return
+			}
+			// the event also fires when the cgroup is removed
+			if _, err := os.Lstat(eventControl); os.IsNotExist(err) {
+				return
+			}
+			ch <- struct{}{}
+		}
+	}()
+	return ch, nil
+}
+
+// notifyOOMV2 watches memory.events with inotify and compares the oom_kill counter
+func notifyOOMV2(subsysCgroupPath string, done <-chan struct{}) (<-chan struct{}, error) {
+	eventsFile := path.Join(subsysCgroupPath, "memory.events")
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init error %v", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, eventsFile, syscall.IN_MODIFY); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("inotify watch %s error %v", eventsFile, err)
+	}
+	inotify := os.NewFile(uintptr(fd), "inotify")
+	lastCount, _ := readKeyedUint(eventsFile, "oom_kill")
+	ch := make(chan struct{})
+	go closeOnDone(done, inotify)
+	go func() {
+		defer func() {
+			close(ch)
+			inotify.Close()
+		}()
+		buf := make([]byte, syscall.SizeofInotifyEvent*16)
+		for {
+			n, err := inotify.Read(buf)
+			if err != nil {
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				// the watch is dropped when the cgroup is removed
+				if event.Mask&syscall.IN_IGNORED != 0 {
+					return
+				}
+				offset += syscall.SizeofInotifyEvent + int(event.Len)
+			}
+			count, err := readKeyedUint(eventsFile, "oom_kill")
+			if err != nil {
+				return
+			}
+			if count > lastCount {
+				lastCount = count
+				ch <- struct{}{}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// closeOnDone closes f once done is closed, which ends the pending read of a watcher
+func closeOnDone(done <-chan struct{}, f *os.File) {
+	if done == nil {
+		return
+	}
+	<-done
+	f.Close()
+}
@@ -0,0 +1,79 @@
+package subsystems
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// PidsSubSystem is an implement of interface SubSystem
+type PidsSubSystem struct {
+}
+
+// Set set cgroup limit according to cgroupPath
+func (s *PidsSubSystem) Set(cgroupPath string, res *ResourceConfig) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, true)
+	if err == nil {
+		if res.PidsLimit != "" {
+			limit, err := strconv.ParseInt(res.PidsLimit, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid pids limit %s: %v", res.PidsLimit, err)
+			}
+			// zero or negative limit means unlimited
+			pidsMax := "max"
+			if limit > 0 {
+				pidsMax = strconv.FormatInt(limit, 10)
+			}
+			if err := ioutil.WriteFile(path.Join(subsysCgroupPath, "pids.max"), []byte(pidsMax), 0644); err != nil {
+				return fmt.Errorf("set cgroup pids fail %v", err)
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// Remove delete cgroup according to cgroupPath
+func (s *PidsSubSystem) Remove(cgroupPath string) error {
+	return removeCgroup(s.Name(), cgroupPath)
+}
+
+// Apply add process to cgroup according to cgroupPath
+func (s *PidsSubSystem) Apply(cgroupPath string, pid int) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err == nil {
+		if err := ioutil.WriteFile(path.Join(subsysCgroupPath, procsFile()), []byte(strconv.Itoa(pid)), 0644); err != nil {
+			return fmt.Errorf("set cgroup proc fail %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("get cgroup %s error: %v", cgroupPath, err)
+}
+
+// GetPids returns the pids of all processes in the cgroup
+func (s *PidsSubSystem) GetPids(cgroupPath string) ([]int, error) {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(path.Join(subsysCgroupPath, "cgroup.procs"))
+	if err != nil {
+		return nil, err
+	}
+	var pids []int
+	for _, field := range strings.Fields(string(content)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid %q in cgroup.procs", field)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// Name returns subsystem name
+func (s *PidsSubSystem) Name() string {
+	return "pids"
+}
@@ -0,0 +1,165 @@
+package subsystems
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Stats is the resource usage read from the accounting files of a cgroup
+type Stats struct {
+	CPUUsage    uint64 `json:"cpuUsage"`    // total CPU time consumed in nanoseconds
+	MemoryUsage uint64 `json:"memoryUsage"` // memory usage in bytes
+	MemoryLimit uint64 `json:"memoryLimit"` // memory limit in bytes, 0 for unlimited
+	PidsCurrent uint64 `json:"pidsCurrent"` // number of processes
+	PidsLimit   uint64 `json:"pidsLimit"`   // max number of processes, 0 for unlimited
+	BlkioRead   uint64 `json:"blkioRead"`   // bytes read from block devices
+	BlkioWrite  uint64 `json:"blkioWrite"`  // bytes written to block devices
+}
+
+// StatsGetter is implemented by subsystems which provide accounting files
+type StatsGetter interface {
+	GetStats(path string, stats *Stats) error
+}
+
+// GetStats reads CPU usage from cpuacct.usage, or cpu.stat on cgroup v2
+func (s *CPUSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	if IsCgroup2UnifiedMode() {
+		subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+		if err != nil {
+			return err
+		}
+		usage, err := readKeyedUint(path.Join(subsysCgroupPath, "cpu.stat"), "usage_usec")
+		if err != nil {
+			return err
+		}
+		stats.CPUUsage = usage * 1000
+		return nil
+	}
+	// cpuacct is usually mounted together with cpu
+	subsysCgroupPath, err := GetCgroupPath("cpuacct", cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	stats.CPUUsage, err = readUint(path.Join(subsysCgroupPath, "cpuacct.usage"))
+	return err
+}
+
+// GetStats reads memory usage and limit
+func (s *MemorySubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	usageFile, limitFile := "memory.usage_in_bytes", "memory.limit_in_bytes"
+	if IsCgroup2UnifiedMode() {
+		usageFile, limitFile = "memory.current", "memory.max"
+	}
+	if stats.MemoryUsage, err = readUint(path.Join(subsysCgroupPath, usageFile)); err != nil {
+		return err
+	}
+	limit, err := readUint(path.Join(subsysCgroupPath, limitFile))
+	if err != nil {
+		return err
+	}
+	// cgroup v1 reports no limit as the largest page aligned int64
+	if limit < 1<<62 {
+		stats.MemoryLimit = limit
+	}
+	return nil
+}
+
+// GetStats reads the number of processes and its limit
+func (s *PidsSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	if stats.PidsCurrent, err = readUint(path.Join(subsysCgroupPath, "pids.current")); err != nil {
+		return err
+	}
+	stats.PidsLimit, err = readUint(path.Join(subsysCgroupPath, "pids.max"))
+	return err
+}
+
+// GetStats sums bytes read and written from blkio.throttle.io_service_bytes, or io.stat on cgroup v2
+func (s *BlkioSubSystem) GetStats(cgroupPath string, stats *Stats) error {
+	subsysCgroupPath, err := GetCgroupPath(s.Name(), cgroupPath, false)
+	if err != nil {
+		return err
+	}
+	statFile := "blkio.throttle.io_service_bytes"
+	if IsCgroup2UnifiedMode() {
+		statFile = "io.stat"
+	}
+	f, err := os.Open(path.Join(subsysCgroupPath, statFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if IsCgroup2UnifiedMode() {
+			// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+			for _, field := range fields[1:] {
+				kv := strings.SplitN(field, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+				value, _ := strconv.ParseUint(kv[1], 10, 64)
+				switch kv[0] {
+				case "rbytes":
+					stats.BlkioRead += value
+				case "wbytes":
+					stats.BlkioWrite += value
+				}
+			}
+			continue
+		}
+		// 8:0 Read 1459200
+		if len(fields) != 3 {
+			continue
+		}
+		value, _ := strconv.ParseUint(fields[2], 10, 64)
+		switch fields[1] {
+		case "Read":
+			stats.BlkioRead += value
+		case "Write":
+			stats.BlkioWrite += value
+		}
+	}
+	return scanner.Err()
+}
+
+// readUint reads a single number from a cgroup file, "max" is read as 0
+func readUint(file string) (uint64, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// readKeyedUint reads the value of key from a flat keyed file such as cpu.stat
+func readKeyedUint(file, key string) (uint64, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("key %s not found in %s", key, file)
+}
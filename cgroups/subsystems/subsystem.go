@@ -1,10 +1,113 @@
 package subsystems
 
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
 // ResourceConfig contains the resource limits items
 type ResourceConfig struct {
-	MemoryLimit string // memory limit
-	CPUShare    string // CPU time-sharing slices
-	CPUSet      string // number of CPU cores
+	MemoryLimit       string       `json:"memoryLimit"`       // memory limit
+	MemorySwap        string       `json:"memorySwap"`        // memory plus swap limit, -1 for unlimited swap
+	MemoryReservation string       `json:"memoryReservation"` // memory soft limit
+	OOMKillDisable    bool         `json:"oomKillDisable"`    // disable OOM killer of the cgroup
+	OOMScoreAdj       int          `json:"oomScoreAdj"`       // oom_score_adj of the container process
+	CPUShare          string       `json:"cpuShare"`          // CPU time-sharing slices
+	CPUSet            string       `json:"cpuSet"`            // number of CPU cores
+	CPUs              string       `json:"cpus"`              // number of CPUs, converted to a CFS quota
+	CPUQuota          string       `json:"cpuQuota"`          // CFS quota in microseconds per period
+	CPUPeriod         string       `json:"cpuPeriod"`         // CFS period in microseconds
+	PidsLimit         string       `json:"pidsLimit"`         // max number of processes
+	BlkioWeight       string       `json:"blkioWeight"`       // relative block IO weight
+	DeviceReadBps     []string     `json:"deviceReadBps"`     // read bytes per second of devices
+	DeviceWriteBps    []string     `json:"deviceWriteBps"`    // write bytes per second of devices
+	DeviceReadIOps    []string     `json:"deviceReadIOps"`    // read operations per second of devices
+	DeviceWriteIOps   []string     `json:"deviceWriteIOps"`   // write operations per second of devices
+	Devices           []DeviceRule `json:"devices"`           // allowed devices, nil for no device policy
+	HugetlbLimits     []string     `json:"hugetlbLimits"`     // huge page limits in the form <pagesize>:<limit>
+}
+
+// Validate checks the limits before they are applied to a cgroup
+func (r *ResourceConfig) Validate() error {
+	if err := r.validateMemory(); err != nil {
+		return err
+	}
+	if r.CPUs != "" && r.CPUQuota != "" {
+		return fmt.Errorf("cpus and cpu-quota can not both provided")
+	}
+	if _, _, err := r.cfsQuotaAndPeriod(); err != nil {
+		return err
+	}
+	for _, spec := range r.HugetlbLimits {
+		if _, err := parseHugetlbLimit(spec); err != nil {
+			return err
+		}
+	}
+	for _, rule := range r.Devices {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.BlkioWeight != "" {
+		weight, err := strconv.ParseUint(r.BlkioWeight, 10, 64)
+		if err != nil || weight < 10 || weight > 1000 {
+			return fmt.Errorf("blkio weight %s out of range [10, 1000]", r.BlkioWeight)
+		}
+	}
+	for _, specs := range [][]string{r.DeviceReadBps, r.DeviceWriteBps} {
+		for _, spec := range specs {
+			if _, err := parseThrottleDevice(spec, true); err != nil {
+				return err
+			}
+		}
+	}
+	for _, specs := range [][]string{r.DeviceReadIOps, r.DeviceWriteIOps} {
+		for _, spec := range specs {
+			if _, err := parseThrottleDevice(spec, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cfsQuotaAndPeriod resolves CPUs, CPUQuota and CPUPeriod to a CFS quota and period,
+// quota is -1 for no limit and 0 means neither value is configured
+func (r *ResourceConfig) cfsQuotaAndPeriod() (int64, uint64, error) {
+	var quota int64
+	var period uint64
+	if r.CPUPeriod != "" {
+		p, err := strconv.ParseUint(r.CPUPeriod, 10, 64)
+		if err != nil || p < 1000 || p > 1000000 {
+			return 0, 0, fmt.Errorf("cpu period %s out of range [1000, 1000000]", r.CPUPeriod)
+		}
+		period = p
+	}
+	switch {
+	case r.CPUs != "":
+		cpus, err := strconv.ParseFloat(r.CPUs, 64)
+		if err != nil || cpus <= 0 {
+			return 0, 0, fmt.Errorf("invalid cpus %s", r.CPUs)
+		}
+		if cpus > float64(runtime.NumCPU()) {
+			return 0, 0, fmt.Errorf("cpus %s exceeds the %d available CPUs", r.CPUs, runtime.NumCPU())
+		}
+		if period == 0 {
+			period = defaultCFSPeriod
+		}
+		quota = int64(cpus * float64(period))
+		if quota < 1000 {
+			return 0, 0, fmt.Errorf("cpus %s is too small for period %d", r.CPUs, period)
+		}
+	case r.CPUQuota != "":
+		q, err := strconv.ParseInt(r.CPUQuota, 10, 64)
+		if err != nil || (q != -1 && q < 1000) {
+			return 0, 0, fmt.Errorf("cpu quota %s must be -1 or at least 1000", r.CPUQuota)
+		}
+		quota = q
+	}
+	return quota, period, nil
 }
 
 // Subsystem describes methods for subsystem instances
@@ -21,5 +124,10 @@ var (
 		&CPUSetSubSystem{},
 		&MemorySubSystem{},
 		&CPUSubSystem{},
+		&PidsSubSystem{},
+		&BlkioSubSystem{},
+		&FreezerSubSystem{},
+		&DevicesSubSystem{},
+		&HugetlbSubSystem{},
 	}
 )
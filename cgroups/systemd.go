@@ -0,0 +1,262 @@
+package cgroups
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSystemdSlice is the slice holding container scopes when no parent is given
+	DefaultSystemdSlice = "xperiMoby.slice"
+	// systemdJobTimeout is how long to wait for systemd to start or stop a unit
+	systemdJobTimeout = 30 * time.Second
+)
+
+// unitProperty is a systemd unit property of D-Bus signature (sv)
+type unitProperty struct {
+	Name  string
+	Value dbus.Variant
+}
+
+// auxUnit is an auxiliary unit of StartTransientUnit, of D-Bus signature (sa(sv))
+type auxUnit struct {
+	Name       string
+	Properties []unitProperty
+}
+
+// SystemdManager runs a container in a transient systemd scope unit, the limits systemd
+// can not express are written to the delegated cgroup of the scope through cgroupfs.
+// It talks to the system bus, which can be replaced by a stand-in bus through
+// DBUS_SYSTEM_BUS_ADDRESS.
+type SystemdManager struct {
+	*CgroupManager
+	unitName string
+	slice    string
+	started  bool
+}
+
+// SystemdCgroupPath returns the cgroup path systemd gives to the scope of a container
+func SystemdCgroupPath(slice, id string) (string, error) {
+	if !strings.HasSuffix(slice, ".slice") {
+		return "", fmt.Errorf("cgroup parent %s is not a systemd slice", slice)
+	}
+	slicePath, err := expandSlice(slice)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(slicePath, scopeName(id)), nil
+}
+
+// NewSystemdManager creates a manager of the scope located at cgroup path
+func NewSystemdManager(cgroupPath string) *SystemdManager {
+	return &SystemdManager{
+		CgroupManager: NewCgroupManager(cgroupPath),
+		unitName:      path.Base(cgroupPath),
+		slice:         path.Base(path.Dir(cgroupPath)),
+	}
+}
+
+// Apply starts the scope unit with pid in it and the resource limits given to Set
+func (m *SystemdManager) Apply(pid int) error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("connect to system bus error %v", err)
+	}
+	properties := []unitProperty{
+		{"Description", dbus.MakeVariant("xperiMoby container " + m.unitName)},
+		{"Slice", dbus.MakeVariant(m.slice)},
+		{"PIDs", dbus.MakeVariant([]uint32{uint32(pid)})},
+		// let us manage the cgroup below the scope
+		{"Delegate", dbus.MakeVariant(true)},
+		{"MemoryAccounting", dbus.MakeVariant(true)},
+		{"CPUAccounting", dbus.MakeVariant(true)},
+		{"TasksAccounting", dbus.MakeVariant(true)},
+	}
+	if m.Resource != nil {
+		resProperties, err := resourceProperties(m.Resource)
+		if err != nil {
+			return err
+		}
+		properties = append(properties, resProperties...)
+	}
+	err = runSystemdJob(conn, "StartTransientUnit", m.unitName, "replace", properties, []auxUnit{})
+	if err != nil {
+		return fmt.Errorf("start unit %s error %v", m.unitName, err)
+	}
+	m.started = true
+	if m.Resource != nil {
+		if err := m.CgroupManager.Set(m.Resource); err != nil {
+			return err
+		}
+	}
+	// join the hierarchies systemd does not manage, such as freezer on cgroup v1
+	return m.CgroupManager.Apply(pid)
+}
+
+// Set records res to be applied when the scope starts, or updates a running scope
+func (m *SystemdManager) Set(res *subsystems.ResourceConfig) error {
+	m.Resource = res
+	if !m.started && !m.unitExists() {
+		return nil
+	}
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("connect to system bus error %v", err)
+	}
+	properties, err := resourceProperties(res)
+	if err != nil {
+		return err
+	}
+	if len(properties) > 0 {
+		obj := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+		call := obj.Call("org.freedesktop.systemd1.Manager.SetUnitProperties", 0, m.unitName, true, properties)
+		if call.Err != nil {
+			return fmt.Errorf("set properties of unit %s error %v", m.unitName, call.Err)
+		}
+	}
+	return m.CgroupManager.Set(res)
+}
+
+// Destroy stops the scope unit and removes what is left of its cgroup
+func (m *SystemdManager) Destroy() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("connect to system bus error %v", err)
+	}
+	if err := runSystemdJob(conn, "StopUnit", m.unitName, "replace"); err != nil {
+		logrus.Warnf("stop unit %s fail %v", m.unitName, err)
+	}
+	return m.CgroupManager.Destroy()
+}
+
+func (m *SystemdManager) unitExists() bool {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return false
+	}
+	var unit dbus.ObjectPath
+	obj := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+	return obj.Call("org.freedesktop.systemd1.Manager.GetUnit", 0, m.unitName).Store(&unit) == nil
+}
+
+// runSystemdJob calls a job creating method of the systemd manager and waits for the job to finish
+func runSystemdJob(conn *dbus.Conn, method string, args ...interface{}) error {
+	const match = "type='signal',interface='org.freedesktop.systemd1.Manager',member='JobRemoved'"
+	if call := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, match); call.Err != nil {
+		return call.Err
+	}
+	defer conn.BusObject().Call("org.freedesktop.DBus.RemoveMatch", 0, match)
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	obj := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+	// systemd only emits JobRemoved to subscribed clients
+	if call := obj.Call("org.freedesktop.systemd1.Manager.Subscribe", 0); call.Err != nil {
+		return call.Err
+	}
+	var job dbus.ObjectPath
+	if err := obj.Call("org.freedesktop.systemd1.Manager."+method, 0, args...).Store(&job); err != nil {
+		return err
+	}
+	timeout := time.After(systemdJobTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			// JobRemoved(u id, o job, s unit, s result)
+			if signal.Name != "org.freedesktop.systemd1.Manager.JobRemoved" || len(signal.Body) < 4 {
+				continue
+			}
+			if removed, ok := signal.Body[1].(dbus.ObjectPath); !ok || removed != job {
+				continue
+			}
+			if result, _ := signal.Body[3].(string); result != "done" {
+				return fmt.Errorf("job %s finished with result %s", job, result)
+			}
+			return nil
+		case <-timeout:
+			return fmt.Errorf("timeout waiting for job %s", job)
+		}
+	}
+}
+
+// resourceProperties maps the limits systemd knows about to unit properties,
+// the others are written to cgroupfs by Set
+func resourceProperties(res *subsystems.ResourceConfig) ([]unitProperty, error) {
+	var properties []unitProperty
+	v2 := subsystems.IsCgroup2UnifiedMode()
+	if res.MemoryLimit != "" {
+		limit, err := subsystems.ParseSize(res.MemoryLimit)
+		if err != nil {
+			return nil, err
+		}
+		name := "MemoryLimit"
+		if v2 {
+			name = "MemoryMax"
+		}
+		properties = append(properties, unitProperty{name, dbus.MakeVariant(uint64(limit))})
+	}
+	if res.CPUShare != "" {
+		if v2 {
+			weight, err := subsystems.SharesToWeight(res.CPUShare)
+			if err != nil {
+				return nil, err
+			}
+			properties = append(properties, unitProperty{"CPUWeight", dbus.MakeVariant(weight)})
+		} else {
+			shares, err := strconv.ParseUint(res.CPUShare, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CPU share %s: %v", res.CPUShare, err)
+			}
+			// systemd rejects what the kernel would clamp when writing cpu.shares
+			if shares < 2 {
+				shares = 2
+			}
+			if shares > 262144 {
+				shares = 262144
+			}
+			properties = append(properties, unitProperty{"CPUShares", dbus.MakeVariant(shares)})
+		}
+	}
+	if res.PidsLimit != "" {
+		limit, err := strconv.ParseInt(res.PidsLimit, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pids limit %s: %v", res.PidsLimit, err)
+		}
+		// zero or negative limit means unlimited, which systemd spells as the max uint64
+		tasksMax := ^uint64(0)
+		if limit > 0 {
+			tasksMax = uint64(limit)
+		}
+		properties = append(properties, unitProperty{"TasksMax", dbus.MakeVariant(tasksMax)})
+	}
+	return properties, nil
+}
+
+// expandSlice converts a slice name to its cgroup path, "a-b.slice" lives in "a.slice/a-b.slice"
+func expandSlice(slice string) (string, error) {
+	name := strings.TrimSuffix(slice, ".slice")
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return "", fmt.Errorf("invalid slice name %s", slice)
+	}
+	var slicePath, prefix string
+	for _, component := range strings.Split(name, "-") {
+		if component == "" {
+			return "", fmt.Errorf("invalid slice name %s", slice)
+		}
+		slicePath = path.Join(slicePath, prefix+component+".slice")
+		prefix += component + "-"
+	}
+	return slicePath, nil
+}
+
+func scopeName(id string) string {
+	return "xperiMoby-" + id + ".scope"
+}
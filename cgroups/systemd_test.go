@@ -0,0 +1,242 @@
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// fakeSystemd implements the parts of org.freedesktop.systemd1.Manager used by SystemdManager
+type fakeSystemd struct {
+	conn *dbus.Conn
+
+	mu         sync.Mutex
+	jobs       uint32
+	properties map[string][]unitProperty
+	// results is the JobRemoved result of a unit, "done" if not set
+	results map[string]string
+}
+
+func (f *fakeSystemd) Subscribe() *dbus.Error {
+	return nil
+}
+
+func (f *fakeSystemd) StartTransientUnit(name, mode string, properties []unitProperty, aux []auxUnit) (dbus.ObjectPath, *dbus.Error) {
+	f.mu.Lock()
+	f.properties[name] = properties
+	f.mu.Unlock()
+	return f.queueJob(name), nil
+}
+
+func (f *fakeSystemd) StopUnit(name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return f.queueJob(name), nil
+}
+
+// queueJob returns a new job which is removed right after the reply is sent
+func (f *fakeSystemd) queueJob(unit string) dbus.ObjectPath {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.jobs++
+	id := f.jobs
+	job := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/systemd1/job/%d", id))
+	result := f.results[unit]
+	if result == "" {
+		result = "done"
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		f.conn.Emit("/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager.JobRemoved", id, job, unit, result)
+	}()
+	return job
+}
+
+// startBus runs a private dbus-daemon with a fake systemd on it and returns a client connection
+func startBus(t *testing.T) (*dbus.Conn, *fakeSystemd) {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	dir, err := ioutil.TempDir("", "systemd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	config := filepath.Join(dir, "bus.conf")
+	if err := ioutil.WriteFile(config, []byte(fmt.Sprintf(busConfig, filepath.Join(dir, "bus"))), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read bus address error %v", err)
+	}
+	address = strings.TrimSpace(address)
+
+	fake := &fakeSystemd{
+		conn:       dial(t, address),
+		properties: map[string][]unitProperty{},
+		results:    map[string]string{},
+	}
+	if err := fake.conn.Export(fake, "/org/freedesktop/systemd1", "org.freedesktop.systemd1.Manager"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := fake.conn.RequestName("org.freedesktop.systemd1", dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("request name error %v, reply %v", err, reply)
+	}
+	return dial(t, address), fake
+}
+
+func dial(t *testing.T, address string) *dbus.Conn {
+	conn, err := dbus.Dial(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestStartTransientUnitProperties(t *testing.T) {
+	conn, fake := startBus(t)
+	properties, err := resourceProperties(&subsystems.ResourceConfig{
+		MemoryLimit: "100m",
+		CPUShare:    "1",
+		PidsLimit:   "0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := scopeName("abc")
+	if err := runSystemdJob(conn, "StartTransientUnit", unit, "replace", properties, []auxUnit{}); err != nil {
+		t.Fatalf("start unit error %v", err)
+	}
+
+	got := map[string]interface{}{}
+	for _, property := range fake.properties[unit] {
+		got[property.Name] = property.Value.Value()
+	}
+	want := map[string]interface{}{
+		"MemoryLimit": uint64(100 << 20),
+		"CPUShares":   uint64(2),
+		"TasksMax":    ^uint64(0),
+	}
+	if subsystems.IsCgroup2UnifiedMode() {
+		want = map[string]interface{}{
+			"MemoryMax": uint64(100 << 20),
+			"CPUWeight": uint64(1),
+			"TasksMax":  ^uint64(0),
+		}
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("property %s = %v, want %v", name, got[name], value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got properties %v, want %v", got, want)
+	}
+}
+
+func TestCPUSharesClamped(t *testing.T) {
+	tests := []struct {
+		shares string
+		want   uint64
+		weight uint64
+	}{
+		{"0", 2, 1},
+		{"1024", 1024, 39},
+		{"262144", 262144, 10000},
+		{"1000000", 262144, 10000},
+	}
+	for _, test := range tests {
+		properties, err := resourceProperties(&subsystems.ResourceConfig{CPUShare: test.shares})
+		if err != nil {
+			t.Fatalf("shares %s: %v", test.shares, err)
+		}
+		name, want := "CPUShares", test.want
+		if subsystems.IsCgroup2UnifiedMode() {
+			name, want = "CPUWeight", test.weight
+		}
+		if len(properties) != 1 || properties[0].Name != name || properties[0].Value.Value() != want {
+			t.Errorf("shares %s: got %v, want %s=%d", test.shares, properties, name, want)
+		}
+	}
+	if _, err := resourceProperties(&subsystems.ResourceConfig{CPUShare: "10abc"}); err == nil {
+		t.Errorf("shares 10abc: expected an error")
+	}
+}
+
+func TestRunSystemdJobWaitsForResult(t *testing.T) {
+	conn, fake := startBus(t)
+	unit := scopeName("failed")
+	fake.results[unit] = "failed"
+	err := runSystemdJob(conn, "StartTransientUnit", unit, "replace", []unitProperty{}, []auxUnit{})
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("start unit error %v, want failed result", err)
+	}
+	if err := runSystemdJob(conn, "StopUnit", scopeName("abc"), "replace"); err != nil {
+		t.Errorf("stop unit error %v", err)
+	}
+}
+
+func TestSystemdCgroupPath(t *testing.T) {
+	tests := []struct {
+		slice string
+		want  string
+		err   bool
+	}{
+		{"xperiMoby.slice", "xperiMoby.slice/xperiMoby-abc.scope", false},
+		{"a-b-c.slice", "a.slice/a-b.slice/a-b-c.slice/xperiMoby-abc.scope", false},
+		{"xperiMoby", "", true},
+		{"-a.slice", "", true},
+		{"a--b.slice", "", true},
+		{"a/b.slice", "", true},
+	}
+	for _, test := range tests {
+		got, err := SystemdCgroupPath(test.slice, "abc")
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("SystemdCgroupPath(%s) = %q, %v, want %q", test.slice, got, err, test.want)
+		}
+	}
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/kasheemlew/xperiMoby/cgroups"
 	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
 	"github.com/kasheemlew/xperiMoby/container"
 	"github.com/kasheemlew/xperiMoby/network"
@@ -11,83 +14,297 @@ import (
 	"github.com/urfave/cli"
 )
 
+var runFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name:  "ti",
+		Usage: "enable tty",
+	},
+	cli.BoolFlag{
+		Name:  "d",
+		Usage: "detach container",
+	},
+	cli.StringFlag{
+		Name:  "m",
+		Usage: "memory limit, e.g. 512m or 2g",
+	},
+	cli.StringFlag{
+		Name:  "memory-swap",
+		Usage: "memory plus swap limit, -1 for unlimited swap",
+	},
+	cli.StringFlag{
+		Name:  "memory-reservation",
+		Usage: "memory soft limit",
+	},
+	cli.BoolFlag{
+		Name:  "oom-kill-disable",
+		Usage: "disable OOM killer",
+	},
+	cli.IntFlag{
+		Name:  "oom-score-adj",
+		Usage: "tune host's OOM preferences (-1000 to 1000)",
+	},
+	cli.StringFlag{
+		Name:  "CPUshare",
+		Usage: "CPUshare limit",
+	},
+	cli.StringFlag{
+		Name:  "CPUset",
+		Usage: "CPUset limit",
+	},
+	cli.StringFlag{
+		Name:  "cpus",
+		Usage: "number of CPUs, e.g. 1.5",
+	},
+	cli.StringFlag{
+		Name:  "cpu-quota",
+		Usage: "CPU CFS quota in microseconds, -1 for unlimited",
+	},
+	cli.StringFlag{
+		Name:  "cpu-period",
+		Usage: "CPU CFS period in microseconds",
+	},
+	cli.StringFlag{
+		Name:  "pids-limit",
+		Usage: "max number of processes, 0 or -1 for unlimited",
+	},
+	cli.StringFlag{
+		Name:  "blkio-weight",
+		Usage: "block IO weight, between 10 and 1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-read-bps",
+		Usage: "limit read rate from a device, e.g. /dev/sda:10mb",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-write-bps",
+		Usage: "limit write rate to a device, e.g. /dev/sda:10mb",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-read-iops",
+		Usage: "limit read operations per second from a device, e.g. /dev/sda:1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "device-write-iops",
+		Usage: "limit write operations per second to a device, e.g. /dev/sda:1000",
+	},
+	cli.StringSliceFlag{
+		Name:  "hugetlb",
+		Usage: "limit huge page usage of a page size, e.g. 2MB:1g",
+	},
+	cli.StringSliceFlag{
+		Name:  "ulimit",
+		Usage: "set a ulimit of the container process, e.g. nofile=65536:65536",
+	},
+	cli.StringFlag{
+		Name:  "v",
+		Usage: "volume, host:container or a container path for an anonymous volume",
+	},
+	cli.StringFlag{
+		Name:  "name",
+		Usage: "container name",
+	},
+	cli.StringSliceFlag{
+		Name:  "e",
+		Usage: "set environment",
+	},
+	cli.StringFlag{
+		Name:  "net",
+		Usage: "container network",
+	},
+	cli.StringSliceFlag{
+		Name:  "p",
+		Usage: "port mapping",
+	},
+	cli.StringFlag{
+		Name:  "cgroup-parent",
+		Value: cgroups.DefaultCgroupParent,
+		Usage: "parent cgroup of the container, a slice with the systemd driver",
+	},
+	cli.StringSliceFlag{
+		Name:  "label, l",
+		Usage: "set metadata on the container, e.g. team=db",
+	},
+	cli.StringSliceFlag{
+		Name:  "label-file",
+		Usage: "read labels from a file of key=value lines",
+	},
+	cli.StringFlag{
+		Name:  "stop-signal",
+		Value: "SIGTERM",
+		Usage: "signal to stop the container",
+	},
+	cli.StringFlag{
+		Name:  "restart",
+		Value: container.RestartNo,
+		Usage: "restart policy of a detached container, no, on-failure[:max-retries], always or unless-stopped",
+	},
+	cli.BoolFlag{
+		Name:  "rm",
+		Usage: "remove the container when it exits",
+	},
+	cli.StringSliceFlag{
+		Name:  "device",
+		Usage: "add a host device to the container, e.g. /dev/fuse:/dev/fuse:rwm",
+	},
+	cli.StringFlag{
+		Name:  "cgroup-driver",
+		Value: cgroups.CgroupfsDriver,
+		Usage: "cgroup driver, cgroupfs or systemd",
+	},
+}
+
 var runCommand = cli.Command{
 	Name: "run",
 	Usage: `Create container with namespace and cgroup limit
 			xperiMoby run -ti [command]`,
-	Flags: []cli.Flag{
-		cli.BoolFlag{
-			Name:  "ti",
-			Usage: "enable tty",
-		},
-		cli.BoolFlag{
-			Name:  "d",
-			Usage: "detach container",
-		},
-		cli.StringFlag{
-			Name:  "m",
-			Usage: "memory limit",
-		},
-		cli.StringFlag{
-			Name:  "CPUshare",
-			Usage: "CPUshare limit",
-		},
-		cli.StringFlag{
-			Name:  "CPUset",
-			Usage: "CPUset limit",
-		},
-		cli.StringFlag{
-			Name:  "v",
-			Usage: "volume",
-		},
-		cli.StringFlag{
-			Name:  "name",
-			Usage: "container name",
-		},
-		cli.StringSliceFlag{
-			Name:  "e",
-			Usage: "set environment",
-		},
-		cli.StringFlag{
-			Name:  "net",
-			Usage: "container network",
-		},
-		cli.StringSliceFlag{
-			Name:  "p",
-			Usage: "port mapping",
-		},
-	},
+	Flags: runFlags,
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container command")
 		}
-
-		resConf := &subsystems.ResourceConfig{
-			MemoryLimit: context.String("m"),
-			CPUSet:      context.String("CPUset"),
-			CPUShare:    context.String("CPUshare"),
-		}
-
-		imageName := context.Args().Get(0)
-		cmdArray := context.Args().Tail()
 		tty := context.Bool("ti")
-		detach := context.Bool("d")
-		if tty && detach {
+		if tty && context.Bool("d") {
 			return fmt.Errorf("ti and d parameter can not both provided")
 		}
-		// network
-		network := context.String("net")
-		portmapping := context.StringSlice("p")
-		// volume
-		volume := context.String("v")
-		containerName := context.String("name")
-		// environ
-		envSlice := context.StringSlice("e")
-		Run(tty, resConf, volume, containerName, imageName, network, cmdArray, envSlice, portmapping)
+		if !tty {
+			// a detached container is started and supervised by its shim
+			return startShim(os.Args[2:])
+		}
+		return runContainer(context, nil)
+	},
+}
+
+var shimCommand = cli.Command{
+	Name:   "shim",
+	Usage:  "Start a detached container and record its exit",
+	Hidden: true,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "container",
+			Usage: "start the stopped container instead of creating one",
+		},
+	}, runFlags...),
+	Action: func(context *cli.Context) error {
+		// fd 3 reports whether the container started to the run command
+		syncPipe := os.NewFile(uintptr(3), "sync")
+		var err error
+		if containerName := context.String("container"); containerName != "" {
+			err = startStoppedContainer(containerName, syncPipe)
+		} else {
+			err = runContainer(context, syncPipe)
+		}
+		if err != nil {
+			syncPipe.WriteString(err.Error())
+			return err
+		}
 		return nil
 	},
 }
 
+// runContainer parses the options of run and starts the container
+func runContainer(context *cli.Context, syncPipe *os.File) error {
+	resConf := &subsystems.ResourceConfig{
+		MemoryLimit:       context.String("m"),
+		MemorySwap:        context.String("memory-swap"),
+		MemoryReservation: context.String("memory-reservation"),
+		OOMKillDisable:    context.Bool("oom-kill-disable"),
+		OOMScoreAdj:       context.Int("oom-score-adj"),
+		CPUSet:            context.String("CPUset"),
+		CPUShare:          context.String("CPUshare"),
+		CPUs:              context.String("cpus"),
+		CPUQuota:          context.String("cpu-quota"),
+		CPUPeriod:         context.String("cpu-period"),
+		PidsLimit:         context.String("pids-limit"),
+		BlkioWeight:       context.String("blkio-weight"),
+		DeviceReadBps:     context.StringSlice("device-read-bps"),
+		DeviceWriteBps:    context.StringSlice("device-write-bps"),
+		DeviceReadIOps:    context.StringSlice("device-read-iops"),
+		DeviceWriteIOps:   context.StringSlice("device-write-iops"),
+		HugetlbLimits:     context.StringSlice("hugetlb"),
+	}
+	var devices []*container.Device
+	for _, spec := range context.StringSlice("device") {
+		device, err := container.ParseDevice(spec)
+		if err != nil {
+			return err
+		}
+		devices = append(devices, device)
+	}
+	resConf.Devices = container.DeviceRules(devices)
+	if err := resConf.Validate(); err != nil {
+		return err
+	}
+	labels, err := parseLabels(context.StringSlice("label"), context.StringSlice("label-file"))
+	if err != nil {
+		return err
+	}
+	stopSignal := context.String("stop-signal")
+	if _, err := parseSignal(stopSignal); err != nil {
+		return err
+	}
+	restartPolicy, err := container.ParseRestartPolicy(context.String("restart"))
+	if err != nil {
+		return err
+	}
+	autoRemove := context.Bool("rm")
+	if autoRemove && restartPolicy.Name != container.RestartNo {
+		return fmt.Errorf("--rm and --restart %s conflict", restartPolicy)
+	}
+	var rlimits []*container.Rlimit
+	for _, spec := range context.StringSlice("ulimit") {
+		rlimit, err := container.ParseUlimit(spec)
+		if err != nil {
+			return err
+		}
+		rlimits = append(rlimits, rlimit)
+	}
+
+	id := randStringBytes(10)
+	containerName := context.String("name")
+	if containerName == "" {
+		containerName = id
+	}
+	// a volume without host directory gets an anonymous one owned by the container
+	volume := context.String("v")
+	var anonymousVolumes []string
+	if volume != "" && !strings.Contains(volume, ":") {
+		hostDir := fmt.Sprintf(container.AnonymousVolumeURL, id)
+		anonymousVolumes = append(anonymousVolumes, hostDir)
+		volume = hostDir + ":" + volume
+	}
+	// every container owns a cgroup named after its id under cgroupParent
+	cgroupDriver := context.String("cgroup-driver")
+	cgroupPath, err := containerCgroupPath(cgroupDriver, context.String("cgroup-parent"), id)
+	if err != nil {
+		return fmt.Errorf("get cgroup path error %v", err)
+	}
+	cmdArray := context.Args().Tail()
+	containerInfo := &container.ContainerInfo{
+		ID:               id,
+		Name:             containerName,
+		Command:          strings.Join(cmdArray, " "),
+		CreatedTime:      time.Now().Format("2006-01-02 15:04:05"),
+		Status:           container.CREATED,
+		Image:            context.Args().Get(0),
+		Args:             cmdArray,
+		Env:              context.StringSlice("e"),
+		Volume:           volume,
+		AnonymousVolumes: anonymousVolumes,
+		Network:          context.String("net"),
+		PortMapping:      context.StringSlice("p"),
+		CgroupPath:       cgroupPath,
+		CgroupDriver:     cgroupDriver,
+		Resource:         resConf,
+		Rlimits:          rlimits,
+		Devices:          devices,
+		Labels:           labels,
+		StopSignal:       stopSignal,
+		RestartPolicy:    restartPolicy,
+		AutoRemove:       autoRemove,
+	}
+	return Run(context.Bool("ti"), containerInfo, syncPipe)
+}
+
 var initCommand = cli.Command{
 	Name:  "init",
 	Usage: "Init container process run user's process in container",
@@ -104,7 +321,10 @@ var commitCommand = cli.Command{
 		if len(context.Args()) < 2 {
 			return fmt.Errorf("Missing image name or container name")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		imageName := context.Args().Get(1)
 		commitContainer(containerName, imageName)
 		return nil
@@ -113,9 +333,29 @@ var commitCommand = cli.Command{
 
 var listCommand = cli.Command{
 	Name:  "ps",
-	Usage: "list all the containers",
+	Usage: "list containers",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "a",
+			Usage: "show all containers, only running ones by default",
+		},
+		cli.BoolFlag{
+			Name:  "q",
+			Usage: "only print container IDs",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter, f",
+			Usage: "filter containers by status, name, id or label, e.g. status=exited,label=team=db",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "table, json or a Go template",
+		},
+	},
 	Action: func(context *cli.Context) error {
-		ListContainers()
+		if err := ListContainers(context.Bool("a"), context.Bool("q"), context.StringSlice("filter"), context.String("format")); err != nil {
+			return fmt.Errorf("list containers error: %v", err)
+		}
 		return nil
 	},
 }
@@ -127,7 +367,10 @@ var logCommand = cli.Command{
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Please input your container name")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		logContainer(containerName)
 		return nil
 	},
@@ -144,7 +387,10 @@ var execCommand = cli.Command{
 		if len(context.Args()) < 2 {
 			return fmt.Errorf("Missing container name or command")
 		}
-		containerName := context.Args().Get(0)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
 		var commandArray []string
 
 		// Tail returns the rest of the arguments (not the first one)
@@ -158,13 +404,183 @@ var execCommand = cli.Command{
 
 var stopCommand = cli.Command{
 	Name:  "stop",
-	Usage: "stop a container",
+	Usage: "stop containers",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Value: int(defaultStopTimeout / time.Second),
+			Usage: "seconds to wait for the container to exit before killing it",
+		},
+		cli.StringSliceFlag{
+			Name:  "filter, f",
+			Usage: "stop the running containers matching a filter, e.g. label=team=db",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 && len(context.StringSlice("filter")) == 0 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerNames, err := selectContainers(context.Args(), context.StringSlice("filter"), false)
+		if err != nil {
+			return err
+		}
+		timeout := time.Duration(context.Int("t")) * time.Second
+		var stopErr error
+		for _, containerName := range containerNames {
+			if err := stopContainer(containerName, timeout); err != nil {
+				logrus.Errorf("Stop container %s error %v", containerName, err)
+				stopErr = fmt.Errorf("stop container error: %v", err)
+			}
+		}
+		return stopErr
+	},
+}
+
+var killCommand = cli.Command{
+	Name:  "kill",
+	Usage: "send a signal to a container",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "s",
+			Value: "SIGKILL",
+			Usage: "signal to send, by name or number",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		signal, err := parseSignal(context.String("s"))
+		if err != nil {
+			return err
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if err := killContainer(containerName, signal); err != nil {
+			return fmt.Errorf("kill container error: %v", err)
+		}
+		return nil
+	},
+}
+
+var pauseCommand = cli.Command{
+	Name:  "pause",
+	Usage: "pause all processes of a container",
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container name")
 		}
-		containerName := context.Args().Get(0)
-		stopContainer(containerName)
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if err := pauseContainer(containerName); err != nil {
+			return fmt.Errorf("pause container error: %v", err)
+		}
+		return nil
+	},
+}
+
+var unpauseCommand = cli.Command{
+	Name:  "unpause",
+	Usage: "unpause all processes of a container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if err := unpauseContainer(containerName); err != nil {
+			return fmt.Errorf("unpause container error: %v", err)
+		}
+		return nil
+	},
+}
+
+var startCommand = cli.Command{
+	Name:  "start",
+	Usage: "start a stopped container",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if err := startContainer(containerName); err != nil {
+			return fmt.Errorf("start container error: %v", err)
+		}
+		return nil
+	},
+}
+
+var restartCommand = cli.Command{
+	Name:  "restart",
+	Usage: "restart a container",
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "t",
+			Value: int(defaultStopTimeout / time.Second),
+			Usage: "seconds to wait for the container to exit before killing it",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		timeout := time.Duration(context.Int("t")) * time.Second
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if err := restartContainer(containerName, timeout); err != nil {
+			return fmt.Errorf("restart container error: %v", err)
+		}
+		return nil
+	},
+}
+
+var inspectCommand = cli.Command{
+	Name:  "inspect",
+	Usage: "display detailed state of containers",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "format the output using a Go template",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		if err := inspectContainers(context.Args(), context.String("format")); err != nil {
+			return fmt.Errorf("inspect container error: %v", err)
+		}
+		return nil
+	},
+}
+
+var waitCommand = cli.Command{
+	Name:  "wait",
+	Usage: "block until a container exits, then print its exit code",
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		code, err := waitContainer(containerName)
+		if err != nil {
+			return fmt.Errorf("wait container error: %v", err)
+		}
+		fmt.Println(code)
 		return nil
 	},
 }
@@ -172,16 +588,182 @@ var stopCommand = cli.Command{
 var removeCommand = cli.Command{
 	Name:  "rm",
 	Usage: "remove unused containers",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "remove the containers matching a filter, e.g. label=team=db",
+		},
+		cli.BoolFlag{
+			Name:  "force, f",
+			Usage: "kill and remove running containers",
+		},
+		cli.BoolFlag{
+			Name:  "volumes, v",
+			Usage: "remove the anonymous volumes of the containers",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 && len(context.StringSlice("filter")) == 0 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerNames, err := selectContainers(context.Args(), context.StringSlice("filter"), true)
+		if err != nil {
+			return err
+		}
+		var removeErr error
+		for _, containerName := range containerNames {
+			if err := removeContainer(containerName, context.Bool("force"), context.Bool("volumes")); err != nil {
+				logrus.Errorf("Remove container %s error %v", containerName, err)
+				removeErr = fmt.Errorf("remove container error: %v", err)
+			}
+		}
+		return removeErr
+	},
+}
+
+var containerCommand = cli.Command{
+	Name:  "container",
+	Usage: "container management commands",
+	Subcommands: []cli.Command{
+		{
+			Name:  "prune",
+			Usage: "remove all stopped and exited containers",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "volumes, v",
+					Usage: "remove the anonymous volumes of the containers",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				return pruneContainers(context.Bool("volumes"))
+			},
+		},
+	},
+}
+
+var updateCommand = cli.Command{
+	Name:  "update",
+	Usage: "update resource limits of a container",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "m",
+			Usage: "memory limit",
+		},
+		cli.StringFlag{
+			Name:  "memory-swap",
+			Usage: "memory plus swap limit, -1 for unlimited swap",
+		},
+		cli.StringFlag{
+			Name:  "memory-reservation",
+			Usage: "memory soft limit",
+		},
+		cli.StringFlag{
+			Name:  "CPUshare",
+			Usage: "CPUshare limit",
+		},
+		cli.StringFlag{
+			Name:  "CPUset",
+			Usage: "CPUset limit",
+		},
+		cli.StringFlag{
+			Name:  "cpus",
+			Usage: "number of CPUs, e.g. 1.5",
+		},
+		cli.StringFlag{
+			Name:  "cpu-quota",
+			Usage: "CPU CFS quota in microseconds, -1 for unlimited",
+		},
+		cli.StringFlag{
+			Name:  "cpu-period",
+			Usage: "CPU CFS period in microseconds",
+		},
+		cli.StringFlag{
+			Name:  "pids-limit",
+			Usage: "max number of processes, 0 or -1 for unlimited",
+		},
+		cli.StringFlag{
+			Name:  "blkio-weight",
+			Usage: "block IO weight, between 10 and 1000",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-read-bps",
+			Usage: "limit read rate from a device, e.g. /dev/sda:10mb",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-write-bps",
+			Usage: "limit write rate to a device, e.g. /dev/sda:10mb",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-read-iops",
+			Usage: "limit read operations per second from a device, e.g. /dev/sda:1000",
+		},
+		cli.StringSliceFlag{
+			Name:  "device-write-iops",
+			Usage: "limit write operations per second to a device, e.g. /dev/sda:1000",
+		},
+	},
 	Action: func(context *cli.Context) error {
 		if len(context.Args()) < 1 {
 			return fmt.Errorf("Missing container name")
 		}
-		containerName := context.Args().Get(0)
-		removeContainer(containerName)
+		resConf := &subsystems.ResourceConfig{
+			MemoryLimit:       context.String("m"),
+			MemorySwap:        context.String("memory-swap"),
+			MemoryReservation: context.String("memory-reservation"),
+			CPUSet:            context.String("CPUset"),
+			CPUShare:          context.String("CPUshare"),
+			CPUs:              context.String("cpus"),
+			CPUQuota:          context.String("cpu-quota"),
+			CPUPeriod:         context.String("cpu-period"),
+			PidsLimit:         context.String("pids-limit"),
+			BlkioWeight:       context.String("blkio-weight"),
+			DeviceReadBps:     context.StringSlice("device-read-bps"),
+			DeviceWriteBps:    context.StringSlice("device-write-bps"),
+			DeviceReadIOps:    context.StringSlice("device-read-iops"),
+			DeviceWriteIOps:   context.StringSlice("device-write-iops"),
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		if err := updateContainer(containerName, resConf); err != nil {
+			return fmt.Errorf("update container error: %v", err)
+		}
 		return nil
 	},
 }
 
+var statsCommand = cli.Command{
+	Name:  "stats",
+	Usage: "display live resource usage of containers",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "no-stream",
+			Usage: "print a single JSON snapshot instead of a refreshing table",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		return statsContainers(context.Args(), context.Bool("no-stream"))
+	},
+}
+
+var topCommand = cli.Command{
+	Name:            "top",
+	Usage:           "display the processes of a container",
+	ArgsUsage:       "CONTAINER [ps OPTIONS]",
+	SkipFlagParsing: true,
+	Action: func(context *cli.Context) error {
+		if len(context.Args()) < 1 {
+			return fmt.Errorf("Missing container name")
+		}
+		containerName, err := resolveContainerName(context.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		return topContainer(containerName, context.Args().Tail())
+	},
+}
+
 var networkCommand = cli.Command{
 	Name:  "network",
 	Usage: "container network commands",
@@ -220,6 +802,25 @@ var networkCommand = cli.Command{
 				return nil
 			},
 		},
+		{
+			Name:  "inspect",
+			Usage: "display detailed state of container networks",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format, f",
+					Usage: "format the output using a Go template",
+				},
+			},
+			Action: func(context *cli.Context) error {
+				if len(context.Args()) < 1 {
+					return fmt.Errorf("Missing network name")
+				}
+				if err := inspectNetworks(context.Args(), context.String("format")); err != nil {
+					return fmt.Errorf("inspect network error: %v", err)
+				}
+				return nil
+			},
+		},
 		{
 			Name:  "remove",
 			Usage: "remove container network",
@@ -0,0 +1,114 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"golang.org/x/sys/unix"
+)
+
+// Device is a device node created in the container and allowed by its cgroup
+type Device struct {
+	Path        string `json:"path"` // path in the container
+	Type        string `json:"type"` // "c" for char or "b" for block device
+	Major       int64  `json:"major"`
+	Minor       int64  `json:"minor"`
+	FileMode    uint32 `json:"fileMode"`
+	Permissions string `json:"permissions"` // cgroup access, any combination of r, w and m
+}
+
+// DefaultDevices are available in every container
+var DefaultDevices = []*Device{
+	{Path: "/dev/null", Type: "c", Major: 1, Minor: 3, FileMode: 0666, Permissions: "rwm"},
+	{Path: "/dev/zero", Type: "c", Major: 1, Minor: 5, FileMode: 0666, Permissions: "rwm"},
+	{Path: "/dev/full", Type: "c", Major: 1, Minor: 7, FileMode: 0666, Permissions: "rwm"},
+	{Path: "/dev/random", Type: "c", Major: 1, Minor: 8, FileMode: 0666, Permissions: "rwm"},
+	{Path: "/dev/urandom", Type: "c", Major: 1, Minor: 9, FileMode: 0666, Permissions: "rwm"},
+	{Path: "/dev/tty", Type: "c", Major: 5, Minor: 0, FileMode: 0666, Permissions: "rwm"},
+	{Path: "/dev/ptmx", Type: "c", Major: 5, Minor: 2, FileMode: 0666, Permissions: "rwm"},
+}
+
+// ParseDevice parses a device of the form <host path>[:<container path>[:<permissions>]],
+// e.g. /dev/fuse:/dev/fuse:rwm
+func ParseDevice(spec string) (*Device, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid device %q", spec)
+	}
+	hostPath, containerPath, permissions := parts[0], parts[0], "rwm"
+	if len(parts) > 1 && parts[1] != "" {
+		containerPath = parts[1]
+	}
+	if len(parts) > 2 {
+		permissions = parts[2]
+	}
+	if !filepath.IsAbs(containerPath) {
+		return nil, fmt.Errorf("device path %s in container must be absolute", containerPath)
+	}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(hostPath, &stat); err != nil {
+		return nil, fmt.Errorf("stat device %s error %v", hostPath, err)
+	}
+	device := &Device{
+		Path:        containerPath,
+		Major:       int64(unix.Major(uint64(stat.Rdev))),
+		Minor:       int64(unix.Minor(uint64(stat.Rdev))),
+		FileMode:    stat.Mode & 0777,
+		Permissions: permissions,
+	}
+	switch stat.Mode & syscall.S_IFMT {
+	case syscall.S_IFCHR:
+		device.Type = "c"
+	case syscall.S_IFBLK:
+		device.Type = "b"
+	default:
+		return nil, fmt.Errorf("%s is not a device", hostPath)
+	}
+	if err := device.Rule().Validate(); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// Rule returns the cgroup rule authorizing the device
+func (d *Device) Rule() subsystems.DeviceRule {
+	return subsystems.DeviceRule{
+		Type:   d.Type,
+		Major:  d.Major,
+		Minor:  d.Minor,
+		Access: d.Permissions,
+	}
+}
+
+// DeviceRules returns the cgroup rules allowing the default devices and devices
+func DeviceRules(devices []*Device) []subsystems.DeviceRule {
+	var rules []subsystems.DeviceRule
+	for _, device := range append(DefaultDevices, devices...) {
+		rules = append(rules, device.Rule())
+	}
+	return rules
+}
+
+// createDevices creates device nodes in the fresh /dev of the container
+func createDevices(devices []*Device) error {
+	oldMask := syscall.Umask(0)
+	defer syscall.Umask(oldMask)
+	for _, device := range devices {
+		if err := os.MkdirAll(filepath.Dir(device.Path), 0755); err != nil {
+			return err
+		}
+		fileType := uint32(syscall.S_IFCHR)
+		if device.Type == "b" {
+			fileType = syscall.S_IFBLK
+		}
+		dev := unix.Mkdev(uint32(device.Major), uint32(device.Minor))
+		if err := syscall.Mknod(device.Path, fileType|device.FileMode, int(dev)); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("mknod %s error %v", device.Path, err)
+		}
+	}
+	return nil
+}
@@ -1,24 +1,40 @@
 package container
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 	"syscall"
 
 	"github.com/sirupsen/logrus"
 )
 
+// InitConfig is sent from the parent to the init process through the pipe
+type InitConfig struct {
+	Args    []string  `json:"args"`
+	Devices []*Device `json:"devices"`
+	Rlimits []*Rlimit `json:"rlimits"`
+}
+
 // RunContainerInitProcess : First process in container
 func RunContainerInitProcess() error {
-	cmdArray := readUserCommand()
-	if cmdArray == nil || len(cmdArray) == 0 {
+	initConfig := readInitConfig()
+	if initConfig == nil || len(initConfig.Args) == 0 {
 		return fmt.Errorf("Run container get user command error, cmdArray is nil")
 	}
+	cmdArray := initConfig.Args
 	setUpMount()
+	if err := createDevices(initConfig.Devices); err != nil {
+		logrus.Errorf("Create devices error %v", err)
+		return err
+	}
+	if err := setRlimits(initConfig.Rlimits); err != nil {
+		logrus.Errorf("Set rlimits error %v", err)
+		return err
+	}
 	path, err := exec.LookPath(cmdArray[0])
 	if err != nil {
 		logrus.Errorf("Exec loop path error %v", err)
@@ -31,7 +47,7 @@ func RunContainerInitProcess() error {
 	return nil
 }
 
-func readUserCommand() []string {
+func readInitConfig() *InitConfig {
 	// 0: stdin, 1: stdout, 2: stderr, 3 should be the first available
 	pipe := os.NewFile(uintptr(3), "pipe")
 	defer pipe.Close()
@@ -40,8 +56,12 @@ func readUserCommand() []string {
 		logrus.Errorf("init read pipe error %v", err)
 		return nil
 	}
-	msgStr := string(msg)
-	return strings.Split(msgStr, " ")
+	var initConfig InitConfig
+	if err := json.Unmarshal(msg, &initConfig); err != nil {
+		logrus.Errorf("init unmarshal config error %v", err)
+		return nil
+	}
+	return &initConfig
 }
 
 func setUpMount() {
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"syscall"
 
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,17 +18,56 @@ type ContainerInfo struct {
 	Command     string   `json:"command"`
 	CreatedTime string   `json:"createTime"`
 	Status      string   `json:"status"`
+	Image       string   `json:"image"`
+	Args        []string `json:"args"`
+	Env         []string `json:"env"`
 	Volume      string   `json:"volume"`
+	Network     string   `json:"network"`
+	IPAddress   string   `json:"ip"`
+	MacAddress  string   `json:"mac"`
 	PortMapping []string `json:"portmapping"`
+	CgroupPath  string   `json:"cgroupPath"`
+	// AnonymousVolumes is the host directories created for volumes given without one,
+	// they are owned by the container and removed by rm -v
+	AnonymousVolumes []string `json:"anonymousVolumes"`
+	// Labels is user defined metadata of the container
+	Labels map[string]string `json:"labels"`
+	// CgroupDriver is the driver managing the cgroup, cgroupfs or systemd
+	CgroupDriver string `json:"cgroupDriver"`
+	// Resource is the resource limits currently applied to the cgroup
+	Resource *subsystems.ResourceConfig `json:"resource"`
+	// Rlimits is the ulimits set on the container process
+	Rlimits []*Rlimit `json:"rlimits"`
+	// Devices is the host devices added to the container besides DefaultDevices
+	Devices []*Device `json:"devices"`
+	// RestartPolicy decides whether the container is started again after it exits
+	RestartPolicy *RestartPolicy `json:"restartPolicy"`
+	// AutoRemove removes the container and its anonymous volumes once it exits
+	AutoRemove bool `json:"autoRemove"`
+	// StopSignal is sent to the container by stop, SIGTERM if empty
+	StopSignal string `json:"stopSignal"`
+	// ManuallyStopped is set by stop so that the shim neither restarts the container
+	// nor marks it exited
+	ManuallyStopped bool `json:"manuallyStopped"`
+	// RestartCount is how many times the shim restarted the container
+	RestartCount int    `json:"restartCount"`
+	OOMKilled    bool   `json:"oomKilled"`
+	ExitCode     int    `json:"exitCode"`
+	FinishedTime string `json:"finishedTime"`
+	// SchemaVersion is the version of the layout of config.json
+	SchemaVersion int `json:"schemaVersion"`
 }
 
 var (
+	CREATED             = "created"
 	RUNNING             = "running"
+	PAUSED              = "paused"
 	STOP                = "stopped"
 	EXIT                = "exited"
 	MntURL              = "/root/xperi/mnt/%s/"
 	RootURL             = "/root/xperi/"
 	WriteLayerURL       = "/root/xperi/writeLayer/%s/"
+	AnonymousVolumeURL  = "/root/xperi/volumes/%s"
 	DefaultInfoLocation = "/var/run/xperiMoby/%s/"
 	ConfigName          = "config.json"
 	ContainerLogFile    = "container.log"
@@ -58,7 +98,8 @@ func NewParentProcess(tty bool, volume, containerName, imageName string, envSlic
 			return nil, nil
 		}
 		stdLogFilePath := dirURL + ContainerLogFile
-		stdLogFile, err := os.Create(stdLogFilePath)
+		// a restarted container appends to the log of its previous runs
+		stdLogFile, err := os.OpenFile(stdLogFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
 			logrus.Errorf("NewParentProcess create file %s error %v", stdLogFilePath, err)
 			return nil, nil
@@ -0,0 +1,69 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Restart policies of a container
+const (
+	RestartNo            = "no"
+	RestartOnFailure     = "on-failure"
+	RestartAlways        = "always"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// RestartPolicy decides whether the shim starts an exited container again
+type RestartPolicy struct {
+	Name              string `json:"name"`
+	MaximumRetryCount int    `json:"maximumRetryCount"` // only for on-failure, 0 for unlimited
+}
+
+// ParseRestartPolicy parses no, on-failure[:max-retries], always or unless-stopped
+func ParseRestartPolicy(policy string) (*RestartPolicy, error) {
+	parts := strings.SplitN(policy, ":", 2)
+	p := &RestartPolicy{Name: parts[0]}
+	switch p.Name {
+	case "", RestartNo:
+		p.Name = RestartNo
+	case RestartAlways, RestartUnlessStopped:
+	case RestartOnFailure:
+		if len(parts) == 2 {
+			count, err := strconv.Atoi(parts[1])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("invalid max retries %q of restart policy", parts[1])
+			}
+			p.MaximumRetryCount = count
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("invalid restart policy %q", policy)
+	}
+	if len(parts) == 2 {
+		return nil, fmt.Errorf("max retries is only allowed for %s restart policy", RestartOnFailure)
+	}
+	return p, nil
+}
+
+// ShouldRestart reports whether a container that exited by itself with exitCode
+// after restartCount restarts is started again, containers stopped by user never are
+func (p *RestartPolicy) ShouldRestart(exitCode, restartCount int) bool {
+	if p == nil {
+		return false
+	}
+	switch p.Name {
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	case RestartOnFailure:
+		return exitCode != 0 && (p.MaximumRetryCount == 0 || restartCount < p.MaximumRetryCount)
+	}
+	return false
+}
+
+func (p *RestartPolicy) String() string {
+	if p.Name == RestartOnFailure && p.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", p.Name, p.MaximumRetryCount)
+	}
+	return p.Name
+}
@@ -0,0 +1,99 @@
+package container
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Rlimit is a resource limit set with setrlimit(2) before the user command starts
+type Rlimit struct {
+	Type string `json:"type"` // name of the resource, e.g. nofile
+	Soft uint64 `json:"soft"`
+	Hard uint64 `json:"hard"`
+}
+
+var rlimitResources = map[string]int{
+	"as":         unix.RLIMIT_AS,
+	"core":       unix.RLIMIT_CORE,
+	"cpu":        unix.RLIMIT_CPU,
+	"data":       unix.RLIMIT_DATA,
+	"fsize":      unix.RLIMIT_FSIZE,
+	"locks":      unix.RLIMIT_LOCKS,
+	"memlock":    unix.RLIMIT_MEMLOCK,
+	"msgqueue":   unix.RLIMIT_MSGQUEUE,
+	"nice":       unix.RLIMIT_NICE,
+	"nofile":     unix.RLIMIT_NOFILE,
+	"nproc":      unix.RLIMIT_NPROC,
+	"rss":        unix.RLIMIT_RSS,
+	"rtprio":     unix.RLIMIT_RTPRIO,
+	"rttime":     unix.RLIMIT_RTTIME,
+	"sigpending": unix.RLIMIT_SIGPENDING,
+	"stack":      unix.RLIMIT_STACK,
+}
+
+// ParseUlimit parses a ulimit of the form <type>=<soft>[:<hard>], e.g. nofile=65536:65536,
+// -1 means unlimited and the hard limit defaults to the soft one
+func ParseUlimit(spec string) (*Rlimit, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid ulimit %q, expected <type>=<soft>[:<hard>]", spec)
+	}
+	if _, ok := rlimitResources[parts[0]]; !ok {
+		return nil, fmt.Errorf("unknown ulimit type %q", parts[0])
+	}
+	limits := strings.Split(parts[1], ":")
+	if len(limits) > 2 {
+		return nil, fmt.Errorf("invalid ulimit %q, too many limits", spec)
+	}
+	soft, err := parseRlimitValue(limits[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ulimit %q: %v", spec, err)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = parseRlimitValue(limits[1]); err != nil {
+			return nil, fmt.Errorf("invalid ulimit %q: %v", spec, err)
+		}
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("invalid ulimit %q, soft limit is larger than hard limit", spec)
+	}
+	return &Rlimit{Type: parts[0], Soft: soft, Hard: hard}, nil
+}
+
+func parseRlimitValue(value string) (uint64, error) {
+	if value == "-1" || value == "unlimited" {
+		return unix.RLIM_INFINITY, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// String formats the limit the way it is given to --ulimit
+func (r *Rlimit) String() string {
+	format := func(v uint64) string {
+		if v == unix.RLIM_INFINITY {
+			return "unlimited"
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	return fmt.Sprintf("%s=%s:%s", r.Type, format(r.Soft), format(r.Hard))
+}
+
+// setRlimits applies rlimits to the current process, they are kept across exec
+func setRlimits(rlimits []*Rlimit) error {
+	for _, rlimit := range rlimits {
+		resource, ok := rlimitResources[rlimit.Type]
+		if !ok {
+			return fmt.Errorf("unknown ulimit type %q", rlimit.Type)
+		}
+		// syscall.Setrlimit keeps the runtime from restoring its own nofile limit on exec
+		if err := syscall.Setrlimit(resource, &syscall.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}); err != nil {
+			return fmt.Errorf("setrlimit %s error %v", rlimit, err)
+		}
+	}
+	return nil
+}
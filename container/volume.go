@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/sirupsen/logrus"
 )
@@ -91,6 +93,15 @@ func MountVolume(volumeURLs []string, containerName string) error {
 
 // DeleteWorkSpace deletes read and write layer when exit
 func DeleteWorkSpace(volume, containerName string) {
+	UnmountWorkSpace(volume, containerName)
+	DeleteWriteLayer(containerName)
+}
+
+// UnmountWorkSpace unmounts the file system of a container and keeps its write layer
+func UnmountWorkSpace(volume, containerName string) {
+	if mounted, _ := isMountPoint(fmt.Sprintf(MntURL, containerName)); !mounted {
+		return
+	}
 	volumeURLs := volumeURLExtract(volume)
 	length := len(volumeURLs)
 	if length == 2 && volumeURLs[0] != "" && volumeURLs[1] != "" {
@@ -98,7 +109,6 @@ func DeleteWorkSpace(volume, containerName string) {
 	} else {
 		DeleteMountPoint(containerName)
 	}
-	DeleteWriteLayer(containerName)
 }
 
 // DeleteMountPointWithVolume unmount volume mount point fs & container mount point fs & remove  the mount points
@@ -168,6 +178,27 @@ func PathExist(path string) (bool, error) {
 	return false, err
 }
 
+// isMountPoint reports whether a file system is mounted on path
+func isMountPoint(path string) (bool, error) {
+	var st, parent syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return false, err
+	}
+	if err := syscall.Stat(filepath.Dir(filepath.Clean(path)), &parent); err != nil {
+		return false, err
+	}
+	return st.Dev != parent.Dev, nil
+}
+
+// ParseVolume splits a volume of the form <host dir>:<container dir>
+func ParseVolume(volume string) (string, string, bool) {
+	volumeURLs := volumeURLExtract(volume)
+	if len(volumeURLs) != 2 || volumeURLs[0] == "" || volumeURLs[1] == "" {
+		return "", "", false
+	}
+	return volumeURLs[0], volumeURLs[1], true
+}
+
 func volumeURLExtract(volume string) []string {
 	var volumeURLs []string
 	volumeURLs = strings.Split(volume, ":")
@@ -5,7 +5,9 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/kasheemlew/xperiMoby/container"
 	_ "github.com/kasheemlew/xperiMoby/nsenter"
+	"github.com/kasheemlew/xperiMoby/store"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,11 +19,16 @@ const EnvExecCmd = "xperiMoby_cmd"
 
 // ExecContainer enters certain ns
 func ExecContainer(containerName string, comArray []string) {
-	pid, err := getContainerPidByName(containerName)
+	containerInfo, err := store.Load(containerName)
 	if err != nil {
-		logrus.Errorf("Exec container getContainerPidByName %s error %v", containerName, err)
+		logrus.Errorf("Exec container load %s error %v", containerName, err)
 		return
 	}
+	if containerInfo.Status == container.PAUSED {
+		logrus.Errorf("Container %s is paused, unpause it first", containerName)
+		return
+	}
+	pid := containerInfo.Pid
 	cmdStr := strings.Join(comArray, " ")
 
 	cmd := exec.Command("/proc/self/exe", "exec")
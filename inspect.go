@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"text/template"
+
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/network"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// containerInspect is the full state of a container printed by inspect
+type containerInspect struct {
+	*container.ContainerInfo
+	RootFS   rootFS           `json:"rootfs"`
+	Mounts   []mountPoint     `json:"mounts"`
+	Endpoint *endpointInspect `json:"endpoint"`
+	// Pids is all processes in the cgroup of a running container
+	Pids []int `json:"pids"`
+}
+
+// rootFS is the layers mounted as the root file system of a container
+type rootFS struct {
+	Image      string `json:"image"`
+	WriteLayer string `json:"writeLayer"`
+	MountPoint string `json:"mountPoint"`
+}
+
+// mountPoint is a volume of a container
+type mountPoint struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// endpointInspect is how a container is connected to its network
+type endpointInspect struct {
+	Network     string   `json:"network"`
+	IPAddress   string   `json:"ip"`
+	MacAddress  string   `json:"mac"`
+	Gateway     string   `json:"gateway"`
+	PortMapping []string `json:"portmapping"`
+}
+
+// networkInspect is the state of a network printed by network inspect
+type networkInspect struct {
+	Name       string             `json:"name"`
+	Driver     string             `json:"driver"`
+	Subnet     string             `json:"subnet"`
+	Gateway    string             `json:"gateway"`
+	Containers []networkContainer `json:"containers"`
+}
+
+// networkContainer is a container connected to a network
+type networkContainer struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IPAddress  string `json:"ip"`
+	MacAddress string `json:"mac"`
+}
+
+// inspectContainers prints the state of containers as JSON or with a Go template
+func inspectContainers(containerRefs []string, format string) error {
+	network.Init()
+	var inspects []interface{}
+	for _, ref := range containerRefs {
+		containerInfo, err := resolveContainer(ref)
+		if err != nil {
+			return err
+		}
+		inspects = append(inspects, newContainerInspect(containerInfo))
+	}
+	return printInspects(inspects, format)
+}
+
+func newContainerInspect(containerInfo *container.ContainerInfo) *containerInspect {
+	inspect := &containerInspect{
+		ContainerInfo: containerInfo,
+		RootFS: rootFS{
+			Image:      container.RootURL + containerInfo.Image,
+			WriteLayer: fmt.Sprintf(container.WriteLayerURL, containerInfo.Name),
+			MountPoint: fmt.Sprintf(container.MntURL, containerInfo.Name),
+		},
+		Mounts: []mountPoint{},
+	}
+	if source, destination, ok := container.ParseVolume(containerInfo.Volume); ok {
+		inspect.Mounts = append(inspect.Mounts, mountPoint{Source: source, Destination: destination})
+	}
+	if containerInfo.Network != "" {
+		inspect.Endpoint = &endpointInspect{
+			Network:     containerInfo.Network,
+			IPAddress:   containerInfo.IPAddress,
+			MacAddress:  containerInfo.MacAddress,
+			PortMapping: containerInfo.PortMapping,
+		}
+		if nw, err := network.GetNetwork(containerInfo.Network); err == nil {
+			inspect.Endpoint.Gateway = nw.IPRange.IP.String()
+		}
+	}
+	if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED {
+		if cgroupManager, err := getCgroupManager(containerInfo); err == nil {
+			inspect.Pids, _ = cgroupManager.GetPids()
+		}
+	}
+	return inspect
+}
+
+// inspectNetworks prints the state of networks and their containers as JSON or with a Go template
+func inspectNetworks(networkNames []string, format string) error {
+	network.Init()
+	containers, err := store.List()
+	if err != nil {
+		return err
+	}
+	var inspects []interface{}
+	for _, networkName := range networkNames {
+		nw, err := network.GetNetwork(networkName)
+		if err != nil {
+			return err
+		}
+		inspect := &networkInspect{
+			Name:       nw.Name,
+			Driver:     nw.Driver,
+			Subnet:     (&net.IPNet{IP: nw.IPRange.IP.Mask(nw.IPRange.Mask), Mask: nw.IPRange.Mask}).String(),
+			Gateway:    nw.IPRange.IP.String(),
+			Containers: []networkContainer{},
+		}
+		for _, item := range containers {
+			if item.Network != nw.Name || item.IPAddress == "" {
+				continue
+			}
+			inspect.Containers = append(inspect.Containers, networkContainer{
+				ID:         item.ID,
+				Name:       item.Name,
+				IPAddress:  item.IPAddress,
+				MacAddress: item.MacAddress,
+			})
+		}
+		inspects = append(inspects, inspect)
+	}
+	return printInspects(inspects, format)
+}
+
+// printInspects prints objects as an indented JSON array, or each of them with format
+func printInspects(inspects []interface{}, format string) error {
+	if format == "" {
+		content, err := json.MarshalIndent(inspects, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+	tmpl, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	for _, inspect := range inspects {
+		if err := tmpl.Execute(os.Stdout, inspect); err != nil {
+			return fmt.Errorf("execute format error %v", err)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// parseFormat parses a Go template given to --format, {{json .}} prints a value as JSON
+func parseFormat(format string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			content, err := json.Marshal(v)
+			return string(content), err
+		},
+	}).Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("parse format error %v", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// killContainer sends signal to the init process of a running container
+func killContainer(containerName string, signal syscall.Signal) error {
+	containerInfo, err := store.Load(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error %v", containerName, err)
+	}
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running", containerName)
+	}
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("convert pid %s error %v", containerInfo.Pid, err)
+	}
+	if err := syscall.Kill(pid, signal); err != nil {
+		return fmt.Errorf("send %v to container %s error %v", signal, containerName, err)
+	}
+	// thaw a paused container being killed, otherwise the cgroup stays frozen
+	// and traps the process of the next start
+	if signal == syscall.SIGKILL && containerInfo.Status == container.PAUSED {
+		cgroupManager, err := getCgroupManager(containerInfo)
+		if err != nil {
+			return err
+		}
+		if err := cgroupManager.Freeze(subsystems.Thawed); err != nil {
+			return fmt.Errorf("thaw container %s error %v", containerName, err)
+		}
+	}
+	return nil
+}
+
+// processExists checks whether pid is alive by sending the null signal
+func processExists(pid int) bool {
+	return syscall.Kill(pid, 0) != syscall.ESRCH
+}
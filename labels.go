@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// parseLabels reads labels given as key=value and from files with one label per line,
+// later files override earlier ones and labels given directly override those from files
+func parseLabels(labels, labelFiles []string) (map[string]string, error) {
+	result := map[string]string{}
+	var all []string
+	for _, file := range labelFiles {
+		fileLabels, err := readLabelFile(file)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, fileLabels...)
+	}
+	for _, label := range append(all, labels...) {
+		parts := strings.SplitN(label, "=", 2)
+		if parts[0] == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", label)
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		result[parts[0]] = value
+	}
+	return result, nil
+}
+
+// readLabelFile reads the labels of a file, blank lines and lines starting with # are skipped
+func readLabelFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open label file %s error %v", file, err)
+	}
+	defer f.Close()
+	var labels []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		labels = append(labels, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read label file %s error %v", file, err)
+	}
+	return labels, nil
+}
+
+// matchLabel reports whether labels satisfy a selector of the form key or key=value
+func matchLabel(labels map[string]string, selector string) bool {
+	parts := strings.SplitN(selector, "=", 2)
+	value, ok := labels[parts[0]]
+	if !ok {
+		return false
+	}
+	return len(parts) == 1 || value == parts[1]
+}
+
+// selectContainers returns the names of the containers given by refs and of those matching filters,
+// only running containers are matched unless all is set
+func selectContainers(refs, filters []string, all bool) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		containerName, err := resolveContainerName(ref)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[containerName] {
+			seen[containerName] = true
+			names = append(names, containerName)
+		}
+	}
+	if len(filters) == 0 {
+		return names, nil
+	}
+	filter, err := parseFilters(filters)
+	if err != nil {
+		return nil, err
+	}
+	containers, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range containers {
+		running := item.Status == container.RUNNING || item.Status == container.PAUSED
+		if (all || running) && filter.match(item) && !seen[item.Name] {
+			seen[item.Name] = true
+			names = append(names, item.Name)
+		}
+	}
+	return names, nil
+}
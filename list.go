@@ -3,70 +3,142 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/kasheemlew/xperiMoby/container"
-	"github.com/sirupsen/logrus"
+	"github.com/kasheemlew/xperiMoby/store"
 )
 
-// ListContainers list the infos of all containers
-func ListContainers() {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, "")
-	dirURL = dirURL[:len(dirURL)-1]
-	files, err := ioutil.ReadDir(dirURL)
+// containerFilter holds the values of each --filter key, a container has to match
+// one of the values of every key
+type containerFilter map[string][]string
+
+// parseFilters parses filters of the form key=value, several of them may be joined by commas
+func parseFilters(specs []string) (containerFilter, error) {
+	filter := containerFilter{}
+	for _, spec := range specs {
+		for _, item := range strings.Split(spec, ",") {
+			parts := strings.SplitN(item, "=", 2)
+			if len(parts) != 2 || parts[1] == "" {
+				return nil, fmt.Errorf("invalid filter %q, expected key=value", item)
+			}
+			switch parts[0] {
+			case "status", "name", "id", "label":
+			default:
+				return nil, fmt.Errorf("unknown filter %q", parts[0])
+			}
+			filter[parts[0]] = append(filter[parts[0]], parts[1])
+		}
+	}
+	return filter, nil
+}
+
+// match reports whether a container passes the filter
+func (f containerFilter) match(item *container.ContainerInfo) bool {
+	for key, values := range f {
+		matched := false
+		for _, value := range values {
+			switch key {
+			case "status":
+				matched = item.Status == value
+			case "name":
+				matched = strings.Contains(item.Name, value)
+			case "id":
+				matched = strings.HasPrefix(item.ID, value)
+			case "label":
+				matched = matchLabel(item.Labels, value)
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// ListContainers list the infos of running containers, or of all of them when all is set,
+// in a table, as JSON lines or with a Go template
+func ListContainers(all, quiet bool, filters []string, format string) error {
+	filter, err := parseFilters(filters)
 	if err != nil {
-		logrus.Errorf("Read dir %s error %v", dirURL, err)
-		return
+		return err
+	}
+	// a status filter selects from all containers
+	if _, ok := filter["status"]; ok {
+		all = true
+	}
+	containers, err := store.List()
+	if err != nil {
+		return err
+	}
+	var selected []*container.ContainerInfo
+	for _, item := range containers {
+		running := item.Status == container.RUNNING || item.Status == container.PAUSED
+		if (all || running) && filter.match(item) {
+			selected = append(selected, item)
+		}
 	}
 
-	var containers []*container.ContainerInfo
-	// traversal for container infos
-	for _, file := range files {
-		if file.Name() == "network" {
-			continue
+	if quiet {
+		for _, item := range selected {
+			fmt.Println(item.ID)
+		}
+		return nil
+	}
+	switch format {
+	case "", "table":
+		return printContainerTable(selected)
+	case "json":
+		for _, item := range selected {
+			content, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(content))
 		}
-		tmpContainer, err := getContainerInfo(file)
-		if err != nil {
-			logrus.Errorf("Get container info error %v", err)
-			continue
+		return nil
+	}
+	tmpl, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	for _, item := range selected {
+		if err := tmpl.Execute(os.Stdout, item); err != nil {
+			return fmt.Errorf("execute format error %v", err)
 		}
-		containers = append(containers, tmpContainer)
+		fmt.Println()
 	}
+	return nil
+}
 
+func printContainerTable(containers []*container.ContainerInfo) error {
 	// write to console
 	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
 	fmt.Fprint(w, "ID\tNAME\tPID\tSTATUS\tCOMMAND\tCREATED\n")
 	for _, item := range containers {
+		status := item.Status
+		if item.Status == container.EXIT {
+			status = fmt.Sprintf("%s (%d)", item.Status, item.ExitCode)
+			if item.OOMKilled {
+				status += " OOMKilled"
+			}
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
 			item.ID,
 			item.Name,
 			item.Pid,
-			item.Status,
+			status,
 			item.Command,
 			item.CreatedTime,
 		)
 	}
 	if err := w.Flush(); err != nil {
-		logrus.Errorf("Flush error %v", err)
-		return
-	}
-}
-
-func getContainerInfo(file os.FileInfo) (*container.ContainerInfo, error) {
-	containerName := file.Name()
-	configFileDir := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFileDir = configFileDir + container.ConfigName
-	content, err := ioutil.ReadFile(configFileDir)
-	if err != nil {
-		logrus.Errorf("Read file %s error %v", configFileDir, err)
-		return nil, err
-	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(content, &containerInfo); err != nil {
-		logrus.Errorf("Json unmarshal error %v", err)
-		return nil, err
+		return fmt.Errorf("flush error %v", err)
 	}
-	return &containerInfo, nil
+	return nil
 }
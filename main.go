@@ -15,12 +15,24 @@ func main() {
 	app.Commands = []cli.Command{
 		initCommand,
 		runCommand,
+		shimCommand,
 		commitCommand,
 		listCommand,
 		logCommand,
 		execCommand,
 		stopCommand,
+		killCommand,
+		startCommand,
+		restartCommand,
+		pauseCommand,
+		unpauseCommand,
+		waitCommand,
 		removeCommand,
+		containerCommand,
+		updateCommand,
+		statsCommand,
+		topCommand,
+		inspectCommand,
 		networkCommand,
 	}
 
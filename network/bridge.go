@@ -131,7 +131,7 @@ func (d *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 	}
 
 	la := netlink.NewLinkAttrs()
-	la.Name = endpoint.ID[:5]
+	la.Name = vethName(endpoint.ID)
 	la.MasterIndex = br.Attrs().Index
 
 	endpoint.Device = netlink.Veth{
@@ -151,5 +151,10 @@ func (d *BridgeNetworkDriver) Connect(network *Network, endpoint *Endpoint) erro
 
 // Disconnect disconnects container from network
 func (d *BridgeNetworkDriver) Disconnect(network *Network, endpoint *Endpoint) error {
-	return nil
+	// the veth pair is usually gone with the network namespace of the container
+	link, err := netlink.LinkByName(vethName(endpoint.ID))
+	if err != nil {
+		return nil
+	}
+	return netlink.LinkDel(link)
 }
@@ -3,12 +3,14 @@ package network
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
@@ -146,15 +148,83 @@ func Connect(networkName string, cinfo *container.ContainerInfo) error {
 		PortMapping: cinfo.PortMapping,
 	}
 	if err = drivers[network.Driver].Connect(network, ep); err != nil {
+		ipAllocator.Release(network.IPRange, &ip)
 		return err
 	}
 	if err = configEndpointIPAddressAndRoute(ep, cinfo); err != nil {
+		drivers[network.Driver].Disconnect(network, ep)
+		ipAllocator.Release(network.IPRange, &ip)
 		return err
 	}
+	cinfo.IPAddress = ip.String()
+	cinfo.MacAddress = ep.MacAddress.String()
 	// configure the port mapping between host and container
 	return configPortMapping(ep, cinfo)
 }
 
+// GetNetwork returns the network named networkName
+func GetNetwork(networkName string) (*Network, error) {
+	network, ok := networks[networkName]
+	if !ok {
+		return nil, fmt.Errorf("No Such Network: %s", networkName)
+	}
+	return network, nil
+}
+
+// Disconnect removes the endpoint of an exited container from network and releases its IP
+func Disconnect(networkName string, cinfo *container.ContainerInfo) error {
+	network, ok := networks[networkName]
+	if !ok {
+		return fmt.Errorf("No Such Network: %s", networkName)
+	}
+	ip := net.ParseIP(cinfo.IPAddress)
+	if ip == nil {
+		return nil
+	}
+	ep := &Endpoint{
+		ID:          fmt.Sprintf("%s-%s", cinfo.ID, network.Name),
+		IPAddress:   ip,
+		Network:     network,
+		PortMapping: cinfo.PortMapping,
+	}
+	if err := drivers[network.Driver].Disconnect(network, ep); err != nil {
+		return err
+	}
+	removePortMapping(ep)
+	if err := ipAllocator.Release(network.IPRange, &ip); err != nil {
+		return err
+	}
+	cinfo.IPAddress = ""
+	cinfo.MacAddress = ""
+	return nil
+}
+
+// vethName is the host side device name of an endpoint, endpoint ids begin with the container id
+func vethName(endpointID string) string {
+	return endpointID[:5]
+}
+
+// InterfaceStats returns the bytes received and transmitted by a container,
+// the counters of the host side veth are reversed from the container's view
+func InterfaceStats(cinfo *container.ContainerInfo) (rx, tx uint64, err error) {
+	statsDir := path.Join("/sys/class/net", vethName(cinfo.ID), "statistics")
+	if tx, err = readCounter(path.Join(statsDir, "rx_bytes")); err != nil {
+		return 0, 0, err
+	}
+	if rx, err = readCounter(path.Join(statsDir, "tx_bytes")); err != nil {
+		return 0, 0, err
+	}
+	return rx, tx, nil
+}
+
+func readCounter(file string) (uint64, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
 // Init inits network configs
 func Init() error {
 	// load network driver
@@ -264,6 +334,7 @@ func configEndpointIPAddressAndRoute(ep *Endpoint, cinfo *container.ContainerInf
 	}
 
 	defer enterContainerNetns(&peerLink, cinfo)()
+	ep.MacAddress = peerLink.Attrs().HardwareAddr
 
 	interfaceIP := *ep.Network.IPRange
 	interfaceIP.IP = ep.IPAddress
@@ -296,14 +367,24 @@ func configEndpointIPAddressAndRoute(ep *Endpoint, cinfo *container.ContainerInf
 }
 
 func configPortMapping(ep *Endpoint, cinfo *container.ContainerInfo) error {
+	iptablesPortMapping("-A", ep)
+	return nil
+}
+
+func removePortMapping(ep *Endpoint) {
+	iptablesPortMapping("-D", ep)
+}
+
+// iptablesPortMapping appends (-A) or deletes (-D) the DNAT rules of the port mapping of ep
+func iptablesPortMapping(action string, ep *Endpoint) {
 	for _, pm := range ep.PortMapping {
 		portMapping := strings.Split(pm, ":")
 		if len(portMapping) != 2 {
 			logrus.Errorf("port mapping format error, %v", pm)
 			continue
 		}
-		iptablesCmd := fmt.Sprintf("-t nat -A PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
-			portMapping[0], ep.IPAddress.String(), portMapping[1])
+		iptablesCmd := fmt.Sprintf("-t nat %s PREROUTING -p tcp -m tcp --dport %s -j DNAT --to-destination %s:%s",
+			action, portMapping[0], ep.IPAddress.String(), portMapping[1])
 		cmd := exec.Command("iptables", strings.Split(iptablesCmd, " ")...)
 		output, err := cmd.Output()
 		if err != nil {
@@ -311,5 +392,4 @@ func configPortMapping(ep *Endpoint, cinfo *container.ContainerInfo) error {
 			continue
 		}
 	}
-	return nil
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// pauseContainer freezes all processes of a running container
+func pauseContainer(containerName string) error {
+	return store.Update(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.RUNNING {
+			return fmt.Errorf("container %s is not running", containerName)
+		}
+		cgroupManager, err := getCgroupManager(containerInfo)
+		if err != nil {
+			return err
+		}
+		if err := cgroupManager.Freeze(subsystems.Frozen); err != nil {
+			return fmt.Errorf("freeze container %s error %v", containerName, err)
+		}
+		containerInfo.Status = container.PAUSED
+		return nil
+	})
+}
+
+// unpauseContainer thaws all processes of a paused container
+func unpauseContainer(containerName string) error {
+	return store.Update(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.Status != container.PAUSED {
+			return fmt.Errorf("container %s is not paused", containerName)
+		}
+		cgroupManager, err := getCgroupManager(containerInfo)
+		if err != nil {
+			return err
+		}
+		if err := cgroupManager.Freeze(subsystems.Thawed); err != nil {
+			return fmt.Errorf("thaw container %s error %v", containerName, err)
+		}
+		containerInfo.Status = container.RUNNING
+		return nil
+	})
+}
@@ -3,25 +3,121 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
 
 	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
 	"github.com/sirupsen/logrus"
 )
 
-func removeContainer(containerName string) {
-	containerInfo, err := getContainerInfoByName(containerName)
+// removeContainer deletes a stopped container, force kills a running one first
+// and volumes also deletes its anonymous volumes
+func removeContainer(containerName string, force, volumes bool) error {
+	containerInfo, err := store.Load(containerName)
 	if err != nil {
-		logrus.Errorf("Get container %s info error %v", containerName, err)
-		return
+		return fmt.Errorf("get container %s info error %v", containerName, err)
 	}
-	if containerInfo.Status != container.STOP {
-		logrus.Errorf("Couldn't remove running container")
-		return
+	if containerInfo.Status == container.RUNNING || containerInfo.Status == container.PAUSED {
+		if !force {
+			return fmt.Errorf("couldn't remove running container %s, stop it first or use --force", containerName)
+		}
+		if err := killForRemoval(containerInfo); err != nil {
+			return fmt.Errorf("kill container %s error %v", containerName, err)
+		}
+		if containerInfo, err = store.Load(containerName); err != nil {
+			return fmt.Errorf("get container %s info error %v", containerName, err)
+		}
 	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.RemoveAll(dirURL); err != nil {
-		logrus.Errorf("Remove file %s error %v", dirURL, err)
-		return
+	// a created container is being started, its launch fails once it is removed
+	if containerInfo.Status == container.CREATED && !force {
+		return fmt.Errorf("couldn't remove container %s being started, use --force", containerName)
+	}
+	if containerInfo.Status != container.STOP && containerInfo.Status != container.EXIT && containerInfo.Status != container.CREATED {
+		return fmt.Errorf("couldn't remove container %s in status %s", containerName, containerInfo.Status)
+	}
+	if containerInfo.CgroupPath != "" {
+		if cgroupManager, err := getCgroupManager(containerInfo); err != nil {
+			logrus.Errorf("Get cgroup manager of container %s error %v", containerName, err)
+		} else {
+			cgroupManager.Destroy()
+		}
+	}
+	if err := store.Delete(containerName); err != nil {
+		return fmt.Errorf("remove container %s info error %v", containerName, err)
 	}
 	container.DeleteWorkSpace(containerInfo.Volume, containerName)
+	if volumes {
+		for _, dir := range containerInfo.AnonymousVolumes {
+			if err := os.RemoveAll(dir); err != nil {
+				logrus.Errorf("Remove volume %s of container %s error %v", dir, containerName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// killForRemoval kills a running container with SIGKILL, waits until its process is gone
+// and marks it stopped
+func killForRemoval(containerInfo *container.ContainerInfo) error {
+	pid, err := strconv.Atoi(containerInfo.Pid)
+	if err != nil {
+		return fmt.Errorf("convert pid %s error %v", containerInfo.Pid, err)
+	}
+	if err := killContainer(containerInfo.Name, syscall.SIGKILL); err != nil {
+		return err
+	}
+	if err := markManuallyStopped(containerInfo.Name); err != nil {
+		return err
+	}
+	if !waitProcessExit(pid, defaultStopTimeout) {
+		return fmt.Errorf("container %s is still running", containerInfo.Name)
+	}
+	return store.Update(containerInfo.Name, func(info *container.ContainerInfo) error {
+		info.Status = container.STOP
+		info.Pid = " "
+		return nil
+	})
+}
+
+// pruneContainers removes every container that is not running and reports
+// the disk space freed from their write layers
+func pruneContainers(volumes bool) error {
+	containers, err := store.List()
+	if err != nil {
+		return err
+	}
+	var reclaimed uint64
+	var pruneErr error
+	for _, item := range containers {
+		if item.Status != container.STOP && item.Status != container.EXIT {
+			continue
+		}
+		size := dirSize(fmt.Sprintf(container.WriteLayerURL, item.Name))
+		if err := removeContainer(item.Name, false, volumes); err != nil {
+			logrus.Errorf("Remove container %s error %v", item.Name, err)
+			pruneErr = fmt.Errorf("prune containers error: %v", err)
+			continue
+		}
+		reclaimed += size
+		fmt.Printf("Deleted: %s\n", item.Name)
+	}
+	fmt.Printf("Total reclaimed space: %s\n", humanSize(reclaimed))
+	return pruneErr
+}
+
+// dirSize sums the size of the regular files under path, 0 if it doesn't exist
+func dirSize(path string) uint64 {
+	var size uint64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
 }
@@ -1,69 +1,230 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/kasheemlew/xperiMoby/cgroups"
-	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
 	"github.com/kasheemlew/xperiMoby/container"
 	"github.com/kasheemlew/xperiMoby/network"
+	"github.com/kasheemlew/xperiMoby/store"
 	"github.com/sirupsen/logrus"
 )
 
-// Run envokes the command
-func Run(tty bool, res *subsystems.ResourceConfig, volume, containerName, imageName, nw string, comArray, envSlice, portmapping []string) {
-	id := randStringBytes(10)
-	if containerName == "" {
-		containerName = id
+// Run creates the container described by containerInfo and starts it, a detached
+// container is supervised until it exits and syncPipe is closed once its init process is started
+func Run(tty bool, containerInfo *container.ContainerInfo, syncPipe *os.File) error {
+	// reserve the name before anything of the container is set up
+	if err := store.Create(containerInfo); err != nil {
+		return err
+	}
+	for _, dir := range containerInfo.AnonymousVolumes {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return abortLaunch(containerInfo, nil, nil, nil, fmt.Errorf("create volume %s error %v", dir, err))
+		}
+	}
+	if tty {
+		return launchContainer(containerInfo, true, nil)
+	}
+	return superviseContainer(containerInfo, syncPipe)
+}
+
+// launchContainer starts the init process of a container from its configuration,
+// which is used both by run and by start of a stopped container
+func launchContainer(containerInfo *container.ContainerInfo, tty bool, syncPipe *os.File) error {
+	cgroupManager, err := getCgroupManager(containerInfo)
+	if err != nil {
+		return abortLaunch(containerInfo, nil, nil, nil, fmt.Errorf("new cgroup manager error %v", err))
 	}
 
-	parent, writePipe := container.NewParentProcess(tty, volume, containerName, imageName, envSlice)
+	parent, writePipe := container.NewParentProcess(tty, containerInfo.Volume, containerInfo.Name, containerInfo.Image, containerInfo.Env)
 	if parent == nil {
-		logrus.Errorf("New parent process error")
-		return
+		return abortLaunch(containerInfo, nil, nil, cgroupManager, fmt.Errorf("new parent process error"))
 	}
 	if err := parent.Start(); err != nil {
-		logrus.Error(err)
-		return
+		return abortLaunch(containerInfo, nil, writePipe, cgroupManager, err)
 	}
+	res := containerInfo.Resource
+	if res.OOMScoreAdj != 0 {
+		if err := setOOMScoreAdj(parent.Process.Pid, res.OOMScoreAdj); err != nil {
+			logrus.Errorf("Set oom_score_adj error %v", err)
+		}
+	}
+	containerInfo.Pid = strconv.Itoa(parent.Process.Pid)
+	containerInfo.Status = container.RUNNING
 
-	if err := recordContainerInfo(parent.Process.Pid, comArray, containerName, volume, id); err != nil {
-		logrus.Errorf("Record container info error %v", err)
-		return
+	// a container must not run without its limits
+	if err := cgroupManager.Set(res); err != nil {
+		return abortLaunch(containerInfo, parent, writePipe, cgroupManager, fmt.Errorf("set cgroup error %v", err))
 	}
-	cgroupManager := cgroups.NewCgroupManager(container.RootURL + "xperiCgroup")
-	defer cgroupManager.Destroy()
-	cgroupManager.Set(res)
 	// add container processes to cgroup
-	cgroupManager.Apply(parent.Process.Pid)
+	if err := cgroupManager.Apply(parent.Process.Pid); err != nil {
+		return abortLaunch(containerInfo, parent, writePipe, cgroupManager, fmt.Errorf("apply cgroup error %v", err))
+	}
 
-	if nw != "" {
-		// config container network
+	if containerInfo.Network != "" {
+		// config container network, Connect releases what it allocated when it fails
 		network.Init()
-		containerInfo := &container.ContainerInfo{
-			ID:          id,
-			Pid:         strconv.Itoa(parent.Process.Pid),
-			Name:        containerName,
-			PortMapping: portmapping,
+		if err := network.Connect(containerInfo.Network, containerInfo); err != nil {
+			return abortLaunch(containerInfo, parent, writePipe, cgroupManager, fmt.Errorf("connect network error %v", err))
+		}
+	}
+	// rm -f may have removed the container while it was set up
+	err = store.Update(containerInfo.Name, func(info *container.ContainerInfo) error {
+		if info.Status != container.CREATED {
+			return fmt.Errorf("container %s is %s", info.Name, info.Status)
 		}
-		if err := network.Connect(nw, containerInfo); err != nil {
-			logrus.Errorf("Error Connect Network %v", err)
-			return
+		info.Pid = containerInfo.Pid
+		info.Status = containerInfo.Status
+		info.IPAddress = containerInfo.IPAddress
+		info.MacAddress = containerInfo.MacAddress
+		return nil
+	})
+	if err != nil {
+		if containerInfo.Network != "" {
+			if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+				logrus.Errorf("Disconnect network error %v", err)
+			}
 		}
+		return abortLaunch(containerInfo, parent, writePipe, cgroupManager, fmt.Errorf("record container info error %v", err))
 	}
-	sendInitCommand(comArray, writePipe)
-	if tty {
+	stopOOMWatch := watchOOM(cgroupManager, containerInfo.Name)
+	// the cgroup is kept across restarts, only kills from now on are of this run
+	oomBase, oomBaseErr := cgroupManager.OOMKillCount()
+	sendInitCommand(containerInfo.Args, containerInfo.Devices, containerInfo.Rlimits, writePipe)
+	if !tty {
+		if syncPipe != nil {
+			syncPipe.Close()
+		}
 		parent.Wait()
-		container.DeleteWorkSpace(volume, containerName)
-		deleteContainerInfo(containerName)
+		oomKilled := stopOOMWatch()
+		// events may be missed, the counter catches them on kernels providing it
+		if count, err := cgroupManager.OOMKillCount(); oomBaseErr == nil && err == nil && count > oomBase {
+			oomKilled = true
+		}
+		if containerInfo.Network != "" {
+			if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+				logrus.Errorf("Disconnect network error %v", err)
+			}
+		}
+		return recordContainerExit(containerInfo.Name, exitCode(parent.ProcessState), oomKilled)
+	}
+	parent.Wait()
+	oomKilled := stopOOMWatch()
+	if count, err := cgroupManager.OOMKillCount(); oomBaseErr == nil && err == nil && count > oomBase {
+		oomKilled = true
+	}
+	if oomKilled {
+		logrus.Warnf("Container %s was killed by the OOM killer", containerInfo.Name)
+	}
+	if containerInfo.Network != "" {
+		if err := network.Disconnect(containerInfo.Network, containerInfo); err != nil {
+			logrus.Errorf("Disconnect network error %v", err)
+		}
+	}
+	cgroupManager.Destroy()
+	container.DeleteWorkSpace(containerInfo.Volume, containerInfo.Name)
+	if containerInfo.AutoRemove {
+		for _, dir := range containerInfo.AnonymousVolumes {
+			os.RemoveAll(dir)
+		}
+	}
+	if err := store.Delete(containerInfo.Name); err != nil {
+		logrus.Errorf("Delete container %s info error %v", containerInfo.Name, err)
 	}
 	os.Exit(0)
+	return nil
+}
+
+// watchOOM watches OOM events of a container until the returned function is called,
+// which reports whether any was seen
+func watchOOM(cgroupManager cgroups.Manager, containerName string) func() bool {
+	done := make(chan struct{})
+	oomCh, err := cgroupManager.NotifyOOM(done)
+	if err != nil {
+		logrus.Warnf("Watch oom events of container %s error %v", containerName, err)
+		return func() bool { return false }
+	}
+	result := make(chan bool, 1)
+	go func() {
+		killed := false
+		for range oomCh {
+			killed = true
+		}
+		result <- killed
+	}()
+	return func() bool {
+		close(done)
+		return <-result
+	}
+}
+
+// abortLaunch undoes the setup of a container that failed to launch and records it exited,
+// so that it can be started again or removed, cause is returned
+func abortLaunch(containerInfo *container.ContainerInfo, parent *exec.Cmd, writePipe *os.File, cgroupManager cgroups.Manager, cause error) error {
+	if writePipe != nil {
+		writePipe.Close()
+	}
+	if parent != nil && parent.Process != nil {
+		parent.Process.Kill()
+		parent.Wait()
+	}
+	if cgroupManager != nil {
+		cgroupManager.Destroy()
+	}
+	container.UnmountWorkSpace(containerInfo.Volume, containerInfo.Name)
+	err := store.Update(containerInfo.Name, func(info *container.ContainerInfo) error {
+		if info.Status != container.CREATED {
+			return nil
+		}
+		info.Status = container.EXIT
+		info.ExitCode = -1
+		info.FinishedTime = time.Now().Format("2006-01-02 15:04:05")
+		info.Pid = " "
+		return nil
+	})
+	if os.IsNotExist(err) {
+		// rm -f removed the container while it was launched
+		container.DeleteWorkSpace(containerInfo.Volume, containerInfo.Name)
+	} else if err != nil {
+		logrus.Errorf("Record failed launch of container %s error %v", containerInfo.Name, err)
+	}
+	return cause
+}
+
+func sendInitCommand(comArray []string, devices []*container.Device, rlimits []*container.Rlimit, writePipe *os.File) {
+	defer writePipe.Close()
+	initConfig := &container.InitConfig{
+		Args:    comArray,
+		Devices: append(container.DefaultDevices, devices...),
+		Rlimits: rlimits,
+	}
+	content, err := json.Marshal(initConfig)
+	if err != nil {
+		logrus.Errorf("Json marshal init config error %v", err)
+		return
+	}
+	writePipe.Write(content)
+}
+
+// setOOMScoreAdj tunes the OOM killer preference of the container process, children inherit it
+func setOOMScoreAdj(pid, score int) error {
+	return ioutil.WriteFile(fmt.Sprintf("/proc/%d/oom_score_adj", pid), []byte(strconv.Itoa(score)), 0644)
 }
 
-func sendInitCommand(comArray []string, writePipe *os.File) {
-	command := strings.Join(comArray, " ")
-	writePipe.WriteString(command)
-	writePipe.Close()
+// containerCgroupPath returns the cgroup of a container, systemd names it after the scope unit
+func containerCgroupPath(cgroupDriver, cgroupParent, id string) (string, error) {
+	if cgroupDriver != cgroups.SystemdDriver {
+		return path.Join(cgroupParent, id), nil
+	}
+	if cgroupParent == cgroups.DefaultCgroupParent {
+		cgroupParent = cgroups.DefaultSystemdSlice
+	}
+	return cgroups.SystemdCgroupPath(cgroupParent, id)
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// startShim runs `xperiMoby shim` with the options of run in its own session, the shim is
+// the parent of the container process and outlives the run command, which returns as soon
+// as the shim reports that the container started
+func startShim(runArgs []string) error {
+	readPipe, writePipe, err := container.NewPipe()
+	if err != nil {
+		return fmt.Errorf("new pipe error %v", err)
+	}
+	defer readPipe.Close()
+	cmd := exec.Command("/proc/self/exe", append([]string{"shim"}, runArgs...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+	cmd.ExtraFiles = []*os.File{writePipe}
+	if err := cmd.Start(); err != nil {
+		writePipe.Close()
+		return fmt.Errorf("start shim error %v", err)
+	}
+	writePipe.Close()
+	// the pipe is closed without a message once the container started
+	msg, err := ioutil.ReadAll(readPipe)
+	if err != nil {
+		return fmt.Errorf("read shim status error %v", err)
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("%s", msg)
+	}
+	return cmd.Process.Release()
+}
+
+const (
+	// initialRestartDelay is the delay before the first restart, it doubles on every restart
+	initialRestartDelay = 100 * time.Millisecond
+	// maxRestartDelay caps the exponential backoff of restarts
+	maxRestartDelay = time.Minute
+	// restartResetTime is how long a container has to run for the backoff to start over
+	restartResetTime = 10 * time.Second
+)
+
+// superviseContainer launches a detached container and starts it again after
+// every exit its restart policy allows, syncPipe is closed by the first launch.
+// A container run with --rm is removed after its last exit
+func superviseContainer(containerInfo *container.ContainerInfo, syncPipe *os.File) error {
+	delay := initialRestartDelay
+	for {
+		started := time.Now()
+		if err := launchContainer(containerInfo, false, syncPipe); err != nil {
+			return err
+		}
+		syncPipe = nil
+		if time.Since(started) >= restartResetTime {
+			delay = initialRestartDelay
+		}
+		if !shouldRestart(containerInfo.Name) {
+			return autoRemoveContainer(containerInfo.Name)
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+		// the container may be stopped, started or removed during the backoff
+		restart := false
+		err := store.Update(containerInfo.Name, func(info *container.ContainerInfo) error {
+			if restart = info.Status == container.EXIT; !restart {
+				return nil
+			}
+			info.RestartCount++
+			prepareRelaunch(info)
+			containerInfo = info
+			return nil
+		})
+		if err != nil || !restart {
+			return nil
+		}
+	}
+}
+
+// autoRemoveContainer removes an exited container run with --rm, the network endpoint
+// was already released when the container exited
+func autoRemoveContainer(containerName string) error {
+	containerInfo, err := store.Load(containerName)
+	if err != nil || !containerInfo.AutoRemove {
+		return nil
+	}
+	return removeContainer(containerName, false, true)
+}
+
+// shouldRestart checks the restart policy of a container that exited by itself
+func shouldRestart(containerName string) bool {
+	containerInfo, err := store.Load(containerName)
+	if err != nil || containerInfo.Status != container.EXIT {
+		return false
+	}
+	return containerInfo.RestartPolicy.ShouldRestart(containerInfo.ExitCode, containerInfo.RestartCount)
+}
+
+// exitCode returns the exit code of an exited process, 128 plus the signal number
+// for a process killed by a signal
+func exitCode(state *os.ProcessState) int {
+	if state == nil {
+		return -1
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return -1
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+// recordContainerExit writes exit information into config.json of a container,
+// exitCode is -1 when it is unknown
+func recordContainerExit(containerName string, exitCode int, oomKilled bool) error {
+	// a process killed by the OOM killer dies of SIGKILL
+	if oomKilled && exitCode == -1 {
+		exitCode = 128 + int(syscall.SIGKILL)
+	}
+	return store.Update(containerName, func(containerInfo *container.ContainerInfo) error {
+		containerInfo.OOMKilled = oomKilled
+		containerInfo.ExitCode = exitCode
+		containerInfo.FinishedTime = time.Now().Format("2006-01-02 15:04:05")
+		containerInfo.Status = container.EXIT
+		if containerInfo.ManuallyStopped {
+			containerInfo.Status = container.STOP
+		}
+		containerInfo.Pid = " "
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// startStoppedContainer relaunches a stopped or exited container from its recorded configuration,
+// the write layer is reused so changes to its file system are kept
+func startStoppedContainer(containerName string, syncPipe *os.File) error {
+	var containerInfo *container.ContainerInfo
+	err := store.Update(containerName, func(info *container.ContainerInfo) error {
+		if info.Status != container.STOP && info.Status != container.EXIT {
+			return fmt.Errorf("container %s is %s", containerName, info.Status)
+		}
+		if info.Image == "" || len(info.Args) == 0 {
+			return fmt.Errorf("container %s has no recorded run configuration", containerName)
+		}
+		info.RestartCount = 0
+		prepareRelaunch(info)
+		containerInfo = info
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return superviseContainer(containerInfo, syncPipe)
+}
+
+// prepareRelaunch clears the state of the previous run of a container and claims it
+// for the new run, so that it is not started twice
+func prepareRelaunch(containerInfo *container.ContainerInfo) {
+	// the old mount may be left over from the previous run
+	container.UnmountWorkSpace(containerInfo.Volume, containerInfo.Name)
+	containerInfo.ManuallyStopped = false
+	containerInfo.OOMKilled = false
+	containerInfo.ExitCode = 0
+	containerInfo.FinishedTime = ""
+	containerInfo.Status = container.CREATED
+	if containerInfo.Resource == nil {
+		containerInfo.Resource = &subsystems.ResourceConfig{}
+	}
+}
+
+// startContainer starts a stopped container under a new shim
+func startContainer(containerName string) error {
+	return startShim([]string{"--container", containerName})
+}
+
+// restartContainer stops a running container, waits for its exit and starts it again
+func restartContainer(containerName string, timeout time.Duration) error {
+	containerInfo, err := store.Load(containerName)
+	if err != nil {
+		return fmt.Errorf("get container %s info error %v", containerName, err)
+	}
+	switch containerInfo.Status {
+	case container.PAUSED:
+		return fmt.Errorf("container %s is paused, unpause it first", containerName)
+	case container.RUNNING:
+		if err := stopContainer(containerName, timeout); err != nil {
+			return err
+		}
+		if _, err := waitContainer(containerName); err != nil {
+			return err
+		}
+	}
+	return startContainer(containerName)
+}
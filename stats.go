@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/network"
+	"github.com/kasheemlew/xperiMoby/store"
+	"github.com/sirupsen/logrus"
+)
+
+// statsInterval is the time between two samples of the resource usage
+const statsInterval = time.Second
+
+// containerStats is the resource usage of a container at one moment
+type containerStats struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	CPUPercent    float64 `json:"cpuPercent"`
+	CPUUsage      uint64  `json:"cpuUsage"`
+	MemoryUsage   uint64  `json:"memoryUsage"`
+	MemoryLimit   uint64  `json:"memoryLimit"`
+	MemoryPercent float64 `json:"memoryPercent"`
+	NetworkRx     uint64  `json:"networkRx"`
+	NetworkTx     uint64  `json:"networkTx"`
+	BlockRead     uint64  `json:"blockRead"`
+	BlockWrite    uint64  `json:"blockWrite"`
+	Pids          uint64  `json:"pids"`
+	sampledAt     time.Time
+}
+
+// statsContainers shows the resource usage of containers, all running ones when names is empty
+func statsContainers(names []string, noStream bool) error {
+	infos, err := statsTargets(names)
+	if err != nil {
+		return err
+	}
+	prev := sampleStats(infos)
+	for {
+		time.Sleep(statsInterval)
+		cur := sampleStats(infos)
+		for i := range cur {
+			cur[i].CPUPercent = cpuPercent(prev[i], cur[i])
+		}
+		if noStream {
+			content, err := json.MarshalIndent(cur, "", "  ")
+			if err != nil {
+				return fmt.Errorf("json marshal stats error %v", err)
+			}
+			fmt.Println(string(content))
+			return nil
+		}
+		// move the cursor home and clear the screen before every refresh
+		fmt.Print("\033[2J\033[H")
+		if err := printStats(cur); err != nil {
+			return err
+		}
+		prev = cur
+	}
+}
+
+func statsTargets(refs []string) ([]*container.ContainerInfo, error) {
+	var infos []*container.ContainerInfo
+	if len(refs) == 0 {
+		containers, err := store.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range containers {
+			if info.Status == container.RUNNING {
+				infos = append(infos, info)
+			}
+		}
+		return infos, nil
+	}
+	for _, ref := range refs {
+		info, err := resolveContainer(ref)
+		if err != nil {
+			return nil, err
+		}
+		if info.Status != container.RUNNING {
+			return nil, fmt.Errorf("container %s is not running", info.Name)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func sampleStats(infos []*container.ContainerInfo) []*containerStats {
+	var samples []*containerStats
+	for _, info := range infos {
+		s := &containerStats{
+			ID:        info.ID,
+			Name:      info.Name,
+			sampledAt: time.Now(),
+		}
+		if cgroupManager, err := getCgroupManager(info); err == nil && info.CgroupPath != "" {
+			cgStats := cgroupManager.GetStats()
+			s.CPUUsage = cgStats.CPUUsage
+			s.MemoryUsage = cgStats.MemoryUsage
+			s.MemoryLimit = cgStats.MemoryLimit
+			s.BlockRead = cgStats.BlkioRead
+			s.BlockWrite = cgStats.BlkioWrite
+			s.Pids = cgStats.PidsCurrent
+		}
+		// containers without a memory limit may use the whole host memory
+		if s.MemoryLimit == 0 {
+			var sysInfo syscall.Sysinfo_t
+			if err := syscall.Sysinfo(&sysInfo); err == nil {
+				s.MemoryLimit = sysInfo.Totalram * uint64(sysInfo.Unit)
+			}
+		}
+		if s.MemoryLimit > 0 {
+			s.MemoryPercent = float64(s.MemoryUsage) / float64(s.MemoryLimit) * 100
+		}
+		// containers without network have no veth
+		if rx, tx, err := network.InterfaceStats(info); err == nil {
+			s.NetworkRx, s.NetworkTx = rx, tx
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// cpuPercent is the share of one CPU used between two samples
+func cpuPercent(prev, cur *containerStats) float64 {
+	elapsed := cur.sampledAt.Sub(prev.sampledAt)
+	if elapsed <= 0 || cur.CPUUsage < prev.CPUUsage {
+		return 0
+	}
+	return float64(cur.CPUUsage-prev.CPUUsage) / float64(elapsed.Nanoseconds()) * 100
+}
+
+func printStats(samples []*containerStats) error {
+	w := tabwriter.NewWriter(os.Stdout, 12, 1, 3, ' ', 0)
+	fmt.Fprint(w, "ID\tNAME\tCPU %\tMEM USAGE / LIMIT\tMEM %\tNET I/O\tBLOCK I/O\tPIDS\n")
+	for _, s := range samples {
+		fmt.Fprintf(w, "%s\t%s\t%.2f%%\t%s / %s\t%.2f%%\t%s / %s\t%s / %s\t%d\n",
+			s.ID,
+			s.Name,
+			s.CPUPercent,
+			humanSize(s.MemoryUsage),
+			humanSize(s.MemoryLimit),
+			s.MemoryPercent,
+			humanSize(s.NetworkRx),
+			humanSize(s.NetworkTx),
+			humanSize(s.BlockRead),
+			humanSize(s.BlockWrite),
+			s.Pids,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		logrus.Errorf("Flush error %v", err)
+		return err
+	}
+	return nil
+}
@@ -1,46 +1,121 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"os"
 	"strconv"
 	"syscall"
+	"time"
 
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
 	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
 	"github.com/sirupsen/logrus"
 )
 
-func stopContainer(containerName string) {
-	pid, err := getContainerPidByName(containerName)
-	if err != nil {
-		logrus.Errorf("Get container pid by name %s error %v", containerName, err)
-		return
+const (
+	// defaultStopTimeout is how long stop waits before it kills the container
+	defaultStopTimeout = 10 * time.Second
+	// stopPollInterval is how often stop checks whether the container process is gone
+	stopPollInterval = 100 * time.Millisecond
+)
+
+// stopContainer sends the stop signal of a container, kills it if it is still alive after timeout
+// and marks it stopped once its process is gone, an exited container waiting to be restarted
+// is only marked stopped
+func stopContainer(containerName string, timeout time.Duration) error {
+	var containerInfo *container.ContainerInfo
+	var pid int
+	stopSignal := syscall.SIGTERM
+	waiting := false
+	err := store.Update(containerName, func(info *container.ContainerInfo) error {
+		// the shim checks the status before every restart
+		if info.Status == container.EXIT && info.RestartPolicy.ShouldRestart(info.ExitCode, info.RestartCount) {
+			info.Status = container.STOP
+			info.ManuallyStopped = true
+			waiting = true
+			return nil
+		}
+		if info.Status != container.RUNNING && info.Status != container.PAUSED {
+			return fmt.Errorf("container %s is not running", containerName)
+		}
+		var err error
+		if pid, err = strconv.Atoi(info.Pid); err != nil {
+			return fmt.Errorf("convert pid %s error %v", info.Pid, err)
+		}
+		if info.StopSignal != "" {
+			if stopSignal, err = parseSignal(info.StopSignal); err != nil {
+				return err
+			}
+		}
+		containerInfo = info
+		return nil
+	})
+	if err != nil || waiting {
+		return err
 	}
-	pidInt, err := strconv.Atoi(pid)
+	cgroupManager, err := getCgroupManager(containerInfo)
 	if err != nil {
-		logrus.Errorf("Convert pid from string to int error %v", err)
-		return
+		return err
 	}
-	if err := syscall.Kill(pidInt, syscall.SIGTERM); err != nil {
-		logrus.Errorf("Stop container %s error %v", containerName, err)
-		return
+	if err := syscall.Kill(pid, stopSignal); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("send %v to container %s error %v", stopSignal, containerName, err)
 	}
-	containerInfo, err := getContainerInfoByName(containerName)
-	if err != nil {
-		logrus.Errorf("Get container %s info error %v", containerName, err)
-		return
+	// the restart policy is only given up once the signal was delivered
+	if err := markManuallyStopped(containerName); err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	containerInfo.Status = container.STOP
-	containerInfo.Pid = " "
-	newContentBytes, err := json.Marshal(containerInfo)
-	if err != nil {
-		logrus.Errorf("Json marshal %s error %v", containerName, err)
-		return
+	// a frozen process handles signals only after it is thawed
+	if containerInfo.Status == container.PAUSED {
+		if err := cgroupManager.Freeze(subsystems.Thawed); err != nil {
+			logrus.Warnf("Thaw container %s error %v", containerName, err)
+		}
+	}
+	if !waitProcessExit(pid, timeout) {
+		logrus.Warnf("Container %s did not exit in %v, killing it", containerName, timeout)
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("kill container %s error %v", containerName, err)
+		}
+		waitProcessExit(pid, defaultStopTimeout)
+	}
+	if processExists(pid) {
+		return fmt.Errorf("container %s is still running", containerName)
 	}
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	if err := ioutil.WriteFile(configFilePath, newContentBytes, 0622); err != nil {
-		logrus.Errorf("Write file %s error %v", configFilePath, err)
+	if err := cgroupManager.Destroy(); err != nil {
+		logrus.Warnf("Destroy cgroup of container %s error %v", containerName, err)
+	}
+	// the shim may have recorded the exit meanwhile, a tty container removes its info on exit
+	err = store.Update(containerName, func(info *container.ContainerInfo) error {
+		info.Status = container.STOP
+		info.Pid = " "
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// markManuallyStopped tells the shim not to restart a container that is being stopped,
+// one that already exited is marked stopped right away
+func markManuallyStopped(containerName string) error {
+	return store.Update(containerName, func(info *container.ContainerInfo) error {
+		info.ManuallyStopped = true
+		if info.Status == container.EXIT {
+			info.Status = container.STOP
+		}
+		return nil
+	})
+}
+
+// waitProcessExit polls until pid is gone or timeout passes and reports whether it is gone
+func waitProcessExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for processExists(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(stopPollInterval)
 	}
+	return true
 }
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/sirupsen/logrus"
+)
+
+// SchemaVersion is the version of ContainerInfo written to config.json by this build,
+// config.json without a version was written before the store existed
+const SchemaVersion = 1
+
+// lockDir holds the files locked with flock(2) while config.json of a container is accessed,
+// they live outside of the container directories so that removing a container never
+// unlinks a lock somebody is waiting on
+const lockDir = ".locks"
+
+// validName is the pattern container names have to match, it keeps them inside the state directory
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// dir returns the directory holding the state of a container
+func dir(containerName string) string {
+	return fmt.Sprintf(container.DefaultInfoLocation, containerName)
+}
+
+// lock takes a shared or exclusive flock on the lock file of a container
+func lock(containerName string, how int) (*os.File, error) {
+	lockURL := path.Join(path.Dir(dir("")), lockDir)
+	if err := os.MkdirAll(lockURL, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path.Join(lockURL, containerName), os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock container %s error %v", containerName, err)
+	}
+	return f, nil
+}
+
+func unlock(f *os.File) {
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
+}
+
+// Load reads the state of a container, the error satisfies os.IsNotExist if it does not exist
+func Load(containerName string) (*container.ContainerInfo, error) {
+	l, err := lock(containerName, syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(l)
+	return load(containerName)
+}
+
+// Create writes the state of a new container and fails if the name is invalid or already taken
+func Create(containerInfo *container.ContainerInfo) error {
+	// network configurations live in the directory a container named network would use
+	if !validName.MatchString(containerInfo.Name) || containerInfo.Name == "network" {
+		return fmt.Errorf("invalid container name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-]* is allowed and network is reserved", containerInfo.Name)
+	}
+	l, err := lock(containerInfo.Name, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock(l)
+	if _, err := os.Stat(path.Join(dir(containerInfo.Name), container.ConfigName)); err == nil {
+		return fmt.Errorf("container name %s is already in use", containerInfo.Name)
+	}
+	if err := os.MkdirAll(dir(containerInfo.Name), 0622); err != nil {
+		return err
+	}
+	return save(containerInfo)
+}
+
+// Update reads the state of a container, lets fn modify it and writes it back while holding
+// the lock, nothing is written if fn fails
+func Update(containerName string, fn func(*container.ContainerInfo) error) error {
+	l, err := lock(containerName, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock(l)
+	containerInfo, err := load(containerName)
+	if err != nil {
+		return err
+	}
+	if err := fn(containerInfo); err != nil {
+		return err
+	}
+	return save(containerInfo)
+}
+
+// Delete removes the state of a container
+func Delete(containerName string) error {
+	l, err := lock(containerName, syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock(l)
+	return os.RemoveAll(dir(containerName))
+}
+
+// List reads the state of all containers, unreadable ones are skipped
+func List() ([]*container.ContainerInfo, error) {
+	rootURL := path.Dir(dir(""))
+	files, err := ioutil.ReadDir(rootURL)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read dir %s error %v", rootURL, err)
+	}
+	var containers []*container.ContainerInfo
+	for _, file := range files {
+		// network configurations and locks live next to the containers
+		if !file.IsDir() || file.Name() == "network" || file.Name() == lockDir {
+			continue
+		}
+		containerInfo, err := Load(file.Name())
+		if err != nil {
+			// a container being created has no config.json yet
+			if !os.IsNotExist(err) {
+				logrus.Errorf("Load container %s error %v", file.Name(), err)
+			}
+			continue
+		}
+		containers = append(containers, containerInfo)
+	}
+	return containers, nil
+}
+
+func load(containerName string) (*container.ContainerInfo, error) {
+	content, err := ioutil.ReadFile(path.Join(dir(containerName), container.ConfigName))
+	if err != nil {
+		return nil, err
+	}
+	var containerInfo container.ContainerInfo
+	if err := json.Unmarshal(content, &containerInfo); err != nil {
+		return nil, fmt.Errorf("unmarshal config of container %s error %v", containerName, err)
+	}
+	if err := migrate(&containerInfo); err != nil {
+		return nil, err
+	}
+	return &containerInfo, nil
+}
+
+// save replaces config.json by renaming a fully written temporary file over it
+func save(containerInfo *container.ContainerInfo) error {
+	containerInfo.SchemaVersion = SchemaVersion
+	content, err := json.Marshal(containerInfo)
+	if err != nil {
+		return fmt.Errorf("json marshal %s error %v", containerInfo.Name, err)
+	}
+	tmp, err := ioutil.TempFile(dir(containerInfo.Name), "."+container.ConfigName)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path.Join(dir(containerInfo.Name), container.ConfigName))
+}
+
+// migrate upgrades state written by older builds to SchemaVersion
+func migrate(containerInfo *container.ContainerInfo) error {
+	if containerInfo.SchemaVersion > SchemaVersion {
+		return fmt.Errorf("container %s was written by a newer version, schema %d", containerInfo.Name, containerInfo.SchemaVersion)
+	}
+	if containerInfo.SchemaVersion == 0 {
+		if containerInfo.Resource == nil {
+			containerInfo.Resource = &subsystems.ResourceConfig{}
+		}
+		if len(containerInfo.Args) == 0 && containerInfo.Command != "" {
+			containerInfo.Args = strings.Split(containerInfo.Command, " ")
+		}
+	}
+	containerInfo.SchemaVersion = SchemaVersion
+	return nil
+}
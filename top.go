@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// clockTicks is USER_HZ, the unit of the cpu times in /proc/<pid>/stat
+const clockTicks = 100
+
+// containerProcess is a process of a container as read from /proc
+type containerProcess struct {
+	Pid     int
+	NSPid   string
+	User    string
+	CPUTime string
+	Command string
+}
+
+// topContainer lists the processes in the cgroup of a container, psArgs are passed
+// to ps whose output is then filtered down to these processes
+func topContainer(containerName string, psArgs []string) error {
+	containerInfo, err := store.Load(containerName)
+	if err != nil {
+		return err
+	}
+	if containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED {
+		return fmt.Errorf("container %s is not running", containerName)
+	}
+	cgroupManager, err := getCgroupManager(containerInfo)
+	if err != nil {
+		return err
+	}
+	pids, err := cgroupManager.GetPids()
+	if err != nil {
+		return fmt.Errorf("get pids of container %s error %v", containerName, err)
+	}
+	if len(psArgs) > 0 {
+		return psFilter(psArgs, pids)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 8, 1, 3, ' ', 0)
+	fmt.Fprint(w, "PID\tCONTAINER PID\tUSER\tTIME\tCOMMAND\n")
+	for _, pid := range pids {
+		process, err := readProcess(pid)
+		if err != nil {
+			// the process exited since the cgroup was read
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n",
+			process.Pid,
+			process.NSPid,
+			process.User,
+			process.CPUTime,
+			process.Command,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("flush error %v", err)
+	}
+	return nil
+}
+
+// readProcess reads the information of a host process from /proc
+func readProcess(pid int) (*containerProcess, error) {
+	process := &containerProcess{Pid: pid}
+	status, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "Uid:":
+			// the real uid comes first
+			process.User = fields[1]
+			if u, err := user.LookupId(fields[1]); err == nil {
+				process.User = u.Username
+			}
+		case "NSpid:":
+			// the last pid is the one in the innermost pid namespace
+			process.NSPid = fields[len(fields)-1]
+		}
+	}
+
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	// comm is enclosed in parentheses and may contain spaces
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 13 {
+		return nil, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	// utime and stime are the 14th and 15th fields of stat
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	seconds := (utime + stime) / clockTicks
+	process.CPUTime = fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return nil, err
+	}
+	process.Command = strings.TrimSpace(strings.Replace(string(cmdline), "\x00", " ", -1))
+	if process.Command == "" {
+		// kernel threads and zombies have no command line
+		process.Command = "[" + string(stat[open+1:end]) + "]"
+	}
+	return process, nil
+}
+
+// psFilter runs ps with args and prints the header and the lines of the given pids
+func psFilter(args []string, pids []int) error {
+	output, err := exec.Command("ps", args...).Output()
+	if err != nil {
+		return fmt.Errorf("run ps %s error %v", strings.Join(args, " "), err)
+	}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	pidIndex := -1
+	for i, name := range strings.Fields(lines[0]) {
+		if name == "PID" {
+			pidIndex = i
+			break
+		}
+	}
+	if pidIndex == -1 {
+		return fmt.Errorf("couldn't find PID field in ps output")
+	}
+	inContainer := map[string]bool{}
+	for _, pid := range pids {
+		inContainer[strconv.Itoa(pid)] = true
+	}
+	fmt.Println(lines[0])
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > pidIndex && inContainer[fields[pidIndex]] {
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
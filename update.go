@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kasheemlew/xperiMoby/cgroups/subsystems"
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// updateContainer applies new resource limits to a running container and persists them
+func updateContainer(containerName string, res *subsystems.ResourceConfig) error {
+	return store.Update(containerName, func(containerInfo *container.ContainerInfo) error {
+		if containerInfo.CgroupPath == "" {
+			return fmt.Errorf("container %s has no cgroup", containerName)
+		}
+		newRes := mergeResourceConfig(containerInfo.Resource, res)
+		if err := newRes.Validate(); err != nil {
+			return err
+		}
+		cgroupManager, err := getCgroupManager(containerInfo)
+		if err != nil {
+			return err
+		}
+		// the device policy cannot change and rewriting it would deny every device for a moment
+		applied := *newRes
+		applied.Devices = nil
+		if err := cgroupManager.Set(&applied); err != nil {
+			return fmt.Errorf("set cgroup of container %s error %v", containerName, err)
+		}
+		containerInfo.Resource = newRes
+		return nil
+	})
+}
+
+// mergeResourceConfig overrides the limits in old with those given in changes
+func mergeResourceConfig(old, changes *subsystems.ResourceConfig) *subsystems.ResourceConfig {
+	merged := &subsystems.ResourceConfig{}
+	if old != nil {
+		*merged = *old
+	}
+	if changes.MemoryLimit != "" {
+		merged.MemoryLimit = changes.MemoryLimit
+	}
+	if changes.MemorySwap != "" {
+		merged.MemorySwap = changes.MemorySwap
+	}
+	if changes.MemoryReservation != "" {
+		merged.MemoryReservation = changes.MemoryReservation
+	}
+	if changes.CPUShare != "" {
+		merged.CPUShare = changes.CPUShare
+	}
+	if changes.CPUSet != "" {
+		merged.CPUSet = changes.CPUSet
+	}
+	// cpus and cpu-quota are two ways to set the same limit
+	if changes.CPUs != "" {
+		merged.CPUs, merged.CPUQuota = changes.CPUs, ""
+	}
+	if changes.CPUQuota != "" {
+		merged.CPUs, merged.CPUQuota = "", changes.CPUQuota
+	}
+	if changes.CPUPeriod != "" {
+		merged.CPUPeriod = changes.CPUPeriod
+	}
+	if changes.PidsLimit != "" {
+		merged.PidsLimit = changes.PidsLimit
+	}
+	if changes.BlkioWeight != "" {
+		merged.BlkioWeight = changes.BlkioWeight
+	}
+	if len(changes.DeviceReadBps) > 0 {
+		merged.DeviceReadBps = changes.DeviceReadBps
+	}
+	if len(changes.DeviceWriteBps) > 0 {
+		merged.DeviceWriteBps = changes.DeviceWriteBps
+	}
+	if len(changes.DeviceReadIOps) > 0 {
+		merged.DeviceReadIOps = changes.DeviceReadIOps
+	}
+	if len(changes.DeviceWriteIOps) > 0 {
+		merged.DeviceWriteIOps = changes.DeviceWriteIOps
+	}
+	return merged
+}
@@ -1,63 +1,21 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/kasheemlew/xperiMoby/cgroups"
 	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
 	"github.com/sirupsen/logrus"
 )
 
-func recordContainerInfo(containerPID int, comArray []string, containerName, volume, id string) error {
-	createTime := time.Now().Format("2006-01-02 15:04:05")
-	command := strings.Join(comArray, " ")
-	containerInfo := &container.ContainerInfo{
-		ID:          id,
-		Pid:         strconv.Itoa(containerPID),
-		Command:     command,
-		CreatedTime: createTime,
-		Status:      container.RUNNING,
-		Name:        containerName,
-		Volume:      volume,
-	}
-	jsonBytes, err := json.Marshal(containerInfo)
-	if err != nil {
-		logrus.Errorf("Record container info err %v", err)
-		return err
-	}
-	jsonStr := string(jsonBytes)
-
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.MkdirAll(dirURL, 0622); err != nil {
-		logrus.Errorf("Mkdir error %s error %v", dirURL, err)
-		return err
-	}
-	fileName := dirURL + container.ConfigName
-	file, err := os.Create(fileName)
-	defer file.Close()
-	if err != nil {
-		logrus.Errorf("Create file %s error %v", fileName, err)
-		return err
-	}
-	// write jsonified data to `config.json`
-	if _, err := file.WriteString(jsonStr); err != nil {
-		logrus.Errorf("File write string error %v", err)
-		return err
-	}
-	return nil
-}
-
-func deleteContainerInfo(containerName string) {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	if err := os.RemoveAll(dirURL); err != nil {
-		logrus.Errorf("Remove dir %s error %v", dirURL, err)
-	}
+// getCgroupManager returns the manager of a container's cgroup for the driver it was created with
+func getCgroupManager(containerInfo *container.ContainerInfo) (cgroups.Manager, error) {
+	return cgroups.NewManager(containerInfo.CgroupDriver, containerInfo.CgroupPath)
 }
 
 func randStringBytes(n int) string {
@@ -71,34 +29,44 @@ func randStringBytes(n int) string {
 	return string(b)
 }
 
-func getContainerInfoByName(containerName string) (*container.ContainerInfo, error) {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	contentBytes, err := ioutil.ReadFile(configFilePath)
+// resolveContainer finds a container by its name, its full ID or an unambiguous prefix of its ID
+func resolveContainer(ref string) (*container.ContainerInfo, error) {
+	if ref == "" {
+		return nil, fmt.Errorf("empty container reference")
+	}
+	containers, err := store.List()
 	if err != nil {
-		logrus.Errorf("Read file %s error %v", configFilePath, err)
 		return nil, err
 	}
-	var containerInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &containerInfo); err != nil {
-		logrus.Errorf("GetContainerInfoByName unmarshal error %v", err)
-		return nil, err
+	for _, item := range containers {
+		if item.Name == ref || item.ID == ref {
+			return item, nil
+		}
+	}
+	var matches []string
+	var match *container.ContainerInfo
+	for _, item := range containers {
+		if strings.HasPrefix(item.ID, ref) {
+			matches = append(matches, item.Name)
+			match = item
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no such container: %s", ref)
+	case 1:
+		return match, nil
 	}
-	return &containerInfo, nil
+	return nil, fmt.Errorf("container reference %s is ambiguous, it matches %s", ref, strings.Join(matches, ", "))
 }
 
-func getContainerPidByName(containerName string) (string, error) {
-	dirURL := fmt.Sprintf(container.DefaultInfoLocation, containerName)
-	configFilePath := dirURL + container.ConfigName
-	contentBytes, err := ioutil.ReadFile(configFilePath)
+// resolveContainerName returns the name of the container ref refers to, see resolveContainer
+func resolveContainerName(ref string) (string, error) {
+	containerInfo, err := resolveContainer(ref)
 	if err != nil {
 		return "", err
 	}
-	var contentInfo container.ContainerInfo
-	if err := json.Unmarshal(contentBytes, &contentInfo); err != nil {
-		return "", err
-	}
-	return contentInfo.Pid, nil
+	return containerInfo.Name, nil
 }
 
 func getEnvByPid(pid string) []string {
@@ -113,3 +81,15 @@ func getEnvByPid(pid string) []string {
 	envs := strings.Split(string(contentBytes), "\u0000")
 	return envs
 }
+
+// humanSize formats bytes with binary units, e.g. 1.5MiB
+func humanSize(bytes uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	size := float64(bytes)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.4g%s", size, units[i])
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kasheemlew/xperiMoby/container"
+	"github.com/kasheemlew/xperiMoby/store"
+)
+
+// waitInterval is how often wait checks whether the container exited
+const waitInterval = 100 * time.Millisecond
+
+// waitContainer blocks until the shim of a container records its exit and returns the exit code
+func waitContainer(containerName string) (int, error) {
+	for {
+		containerInfo, err := store.Load(containerName)
+		if err != nil {
+			return -1, fmt.Errorf("get container %s info error %v", containerName, err)
+		}
+		exited := containerInfo.Status != container.RUNNING && containerInfo.Status != container.PAUSED
+		if exited && containerInfo.FinishedTime != "" {
+			return containerInfo.ExitCode, nil
+		}
+		time.Sleep(waitInterval)
+	}
+}